@@ -21,7 +21,7 @@ func (v *View) deselect(index int) bool {
 // ScrollUpAction scrolls the view up
 func (v *View) ScrollUpAction() bool {
 	if v.mainCursor() {
-		scrollspeed := int(v.Buf.Settings["scrollspeed"].(float64))
+		scrollspeed := v.Buf.Settings.Int("scrollspeed")
 		v.ScrollUp(scrollspeed)
 	}
 	return false
@@ -30,7 +30,7 @@ func (v *View) ScrollUpAction() bool {
 // ScrollDownAction scrolls the view up
 func (v *View) ScrollDownAction() bool {
 	if v.mainCursor() {
-		scrollspeed := int(v.Buf.Settings["scrollspeed"].(float64))
+		scrollspeed := v.Buf.Settings.Int("scrollspeed")
 		v.ScrollDown(scrollspeed)
 	}
 	return false
@@ -51,14 +51,24 @@ func (v *View) Center() bool {
 // CursorUp moves the cursor up
 func (v *View) CursorUp() bool {
 	v.deselect(0)
-	v.Cursor.Up()
+	if v.Buf.Settings.Bool("softwrap") {
+		v.moveVisualRow(-1)
+	} else {
+		v.Cursor.Up()
+	}
+	v.skipFolds(-1)
 	return true
 }
 
 // CursorDown moves the cursor down
 func (v *View) CursorDown() bool {
 	v.deselect(1)
-	v.Cursor.Down()
+	if v.Buf.Settings.Bool("softwrap") {
+		v.moveVisualRow(1)
+	} else {
+		v.Cursor.Down()
+	}
+	v.skipFolds(1)
 	return true
 }
 
@@ -69,10 +79,10 @@ func (v *View) CursorLeft() bool {
 		v.Cursor.ResetSelection()
 		v.Cursor.StoreVisualX()
 	} else {
-		tabstospaces := v.Buf.Settings["tabstospaces"].(bool)
-		tabmovement := v.Buf.Settings["tabmovement"].(bool)
+		tabstospaces := v.Buf.Settings.Bool("tabstospaces")
+		tabmovement := v.Buf.Settings.Bool("tabmovement")
 		if tabstospaces && tabmovement {
-			tabsize := int(v.Buf.Settings["tabsize"].(float64))
+			tabsize := v.Buf.Settings.Int("tabsize")
 			line := v.Buf.Line(v.Cursor.Y)
 			if v.Cursor.X-tabsize >= 0 && line[v.Cursor.X-tabsize:v.Cursor.X] == Spaces(tabsize) && IsStrWhitespace(line[0:v.Cursor.X-tabsize]) {
 				for i := 0; i < tabsize; i++ {
@@ -95,10 +105,10 @@ func (v *View) CursorRight() bool {
 		v.Cursor.ResetSelection()
 		v.Cursor.StoreVisualX()
 	} else {
-		tabstospaces := v.Buf.Settings["tabstospaces"].(bool)
-		tabmovement := v.Buf.Settings["tabmovement"].(bool)
+		tabstospaces := v.Buf.Settings.Bool("tabstospaces")
+		tabmovement := v.Buf.Settings.Bool("tabmovement")
 		if tabstospaces && tabmovement {
-			tabsize := int(v.Buf.Settings["tabsize"].(float64))
+			tabsize := v.Buf.Settings.Int("tabsize")
 			line := v.Buf.Line(v.Cursor.Y)
 			if v.Cursor.X+tabsize < Count(line) && line[v.Cursor.X:v.Cursor.X+tabsize] == Spaces(tabsize) && IsStrWhitespace(line[0:v.Cursor.X]) {
 				for i := 0; i < tabsize; i++ {
@@ -282,8 +292,8 @@ func (v *View) ParagraphNext() bool {
 // Retab changes all tabs to spaces or all spaces to tabs depending
 // on the user's settings
 func (v *View) Retab() bool {
-	toSpaces := v.Buf.Settings["tabstospaces"].(bool)
-	tabsize := int(v.Buf.Settings["tabsize"].(float64))
+	toSpaces := v.Buf.Settings.Bool("tabstospaces")
+	tabsize := v.Buf.Settings.Int("tabsize")
 	dirty := false
 
 	for i := 0; i < v.Buf.NumLines; i++ {
@@ -371,7 +381,7 @@ func (v *View) InsertNewline() bool {
 	v.Buf.Insert(v.Cursor.Loc, "\n")
 	// v.Cursor.Right()
 
-	if v.Buf.Settings["autoindent"].(bool) {
+	if v.Buf.Settings.Bool("autoindent") {
 		if cx < len(ws) {
 			ws = ws[0:cx]
 		}
@@ -381,7 +391,7 @@ func (v *View) InsertNewline() bool {
 		// }
 
 		// Remove the whitespaces if keepautoindent setting is off
-		if IsSpacesOrTabs(v.Buf.Line(v.Cursor.Y-1)) && !v.Buf.Settings["keepautoindent"].(bool) {
+		if IsSpacesOrTabs(v.Buf.Line(v.Cursor.Y-1)) && !v.Buf.Settings.Bool("keepautoindent") {
 			line := v.Buf.Line(v.Cursor.Y - 1)
 			v.Buf.Remove(Loc{0, v.Cursor.Y - 1}, Loc{Count(line), v.Cursor.Y - 1})
 		}
@@ -408,8 +418,8 @@ func (v *View) Backspace() bool {
 		// whitespace at the start of the line, we should delete as if it's a
 		// tab (tabSize number of spaces)
 		lineStart := sliceEnd(v.Buf.LineBytes(v.Cursor.Y), v.Cursor.X)
-		tabSize := int(v.Buf.Settings["tabsize"].(float64))
-		if v.Buf.Settings["tabstospaces"].(bool) && IsSpaces(lineStart) && utf8.RuneCount(lineStart) != 0 && utf8.RuneCount(lineStart)%tabSize == 0 {
+		tabSize := v.Buf.Settings.Int("tabsize")
+		if v.Buf.Settings.Bool("tabstospaces") && IsSpaces(lineStart) && utf8.RuneCount(lineStart) != 0 && utf8.RuneCount(lineStart)%tabSize == 0 {
 			loc := v.Cursor.Loc
 			v.Buf.Remove(loc.Move(-tabSize, v.Buf), loc)
 		} else {
@@ -592,6 +602,16 @@ func (v *View) InsertTab() bool {
 
 // Undo undoes the last action
 func (v *View) Undo() bool {
+	if v.Buf.Binary() {
+		if v.Readonly {
+			return false
+		}
+		if offset := v.Buf.HexUndo(); offset >= 0 {
+			v.hexMove(offset)
+		}
+		return true
+	}
+
 	if v.Buf.curCursor == 0 {
 		v.Buf.clearCursors()
 	}
@@ -602,6 +622,16 @@ func (v *View) Undo() bool {
 
 // Redo redoes the last action
 func (v *View) Redo() bool {
+	if v.Buf.Binary() {
+		if v.Readonly {
+			return false
+		}
+		if offset := v.Buf.HexRedo(); offset >= 0 {
+			v.hexMove(offset)
+		}
+		return true
+	}
+
 	if v.Buf.curCursor == 0 {
 		v.Buf.clearCursors()
 	}
@@ -765,6 +795,191 @@ func (v *View) JumpToMatchingBrace() bool {
 	return true
 }
 
+// DiffNext moves the cursor to the start of the next changed region of the
+// buffer according to the diff gutter
+func (v *View) DiffNext() bool {
+	v.Buf.updateDiff()
+	for _, h := range v.Buf.diffHunks {
+		if h.start > v.Cursor.Y && h.start < v.Buf.NumLines {
+			v.deselect(0)
+			v.Cursor.GotoLoc(Loc{0, h.start})
+			return true
+		}
+	}
+	return false
+}
+
+// DiffPrevious moves the cursor to the start of the previous changed region
+// of the buffer according to the diff gutter
+func (v *View) DiffPrevious() bool {
+	v.Buf.updateDiff()
+	for i := len(v.Buf.diffHunks) - 1; i >= 0; i-- {
+		h := v.Buf.diffHunks[i]
+		if h.start < v.Cursor.Y {
+			v.deselect(0)
+			v.Cursor.GotoLoc(Loc{0, h.start})
+			return true
+		}
+	}
+	return false
+}
+
+// DiffRevertHunk reverts the changed region under the cursor to the
+// contents of the diff base
+func (v *View) DiffRevertHunk() bool {
+	if v.Cursor.HasSelection() {
+		v.Cursor.ResetSelection()
+	}
+	if v.Buf.RevertDiffHunk(v.Cursor.Y) {
+		v.Cursor.Relocate()
+		return true
+	}
+	return false
+}
+
+// ConflictNext moves the cursor to the next merge conflict block
+func (v *View) ConflictNext() bool {
+	conflicts := v.Buf.Conflicts()
+	if len(conflicts) == 0 {
+		return false
+	}
+
+	next := conflicts[0]
+	for _, c := range conflicts {
+		if c.Start > v.Cursor.Y {
+			next = c
+			break
+		}
+	}
+	v.deselect(0)
+	v.Cursor.GotoLoc(Loc{0, next.Start})
+	return true
+}
+
+// ConflictTakeOurs resolves the conflict block under the cursor by keeping
+// our side
+func (v *View) ConflictTakeOurs() bool {
+	c, ok := v.Buf.ConflictAt(v.Cursor.Y)
+	if !ok {
+		return false
+	}
+	start, end := c.Ours()
+	return v.resolveConflict([2]int{start, end})
+}
+
+// ConflictTakeTheirs resolves the conflict block under the cursor by keeping
+// their side
+func (v *View) ConflictTakeTheirs() bool {
+	c, ok := v.Buf.ConflictAt(v.Cursor.Y)
+	if !ok {
+		return false
+	}
+	start, end := c.Theirs()
+	return v.resolveConflict([2]int{start, end})
+}
+
+// ConflictTakeBoth resolves the conflict block under the cursor by keeping
+// our side followed by their side
+func (v *View) ConflictTakeBoth() bool {
+	c, ok := v.Buf.ConflictAt(v.Cursor.Y)
+	if !ok {
+		return false
+	}
+	oursStart, oursEnd := c.Ours()
+	theirsStart, theirsEnd := c.Theirs()
+	return v.resolveConflict([2]int{oursStart, oursEnd}, [2]int{theirsStart, theirsEnd})
+}
+
+// Fold folds the innermost range around the cursor
+func (v *View) Fold() bool {
+	r, ok := v.Buf.FoldRangeAt(v.Cursor.Y)
+	if !ok {
+		return false
+	}
+	v.Buf.CloseFold(r)
+	v.deselect(0)
+	v.Cursor.GotoLoc(Loc{0, r.Start})
+	return true
+}
+
+// Unfold unfolds the fold on the cursor's line
+func (v *View) Unfold() bool {
+	return v.Buf.OpenFold(v.Cursor.Y)
+}
+
+// ToggleAllFolds unfolds everything if anything is folded, and otherwise folds
+// all of the outermost fold ranges in the buffer
+func (v *View) ToggleAllFolds() bool {
+	if !v.mainCursor() {
+		return false
+	}
+
+	if len(v.Buf.folds) > 0 {
+		v.Buf.OpenAllFolds()
+		return true
+	}
+
+	for lineN := 0; lineN < v.Buf.NumLines; lineN++ {
+		if r, ok := v.Buf.foldRangeStartingAt(lineN); ok {
+			v.Buf.CloseFold(r)
+			lineN = r.End
+		}
+	}
+
+	// Move every cursor out of the lines that were hidden
+	cursor := v.Cursor
+	for _, c := range v.Buf.cursors {
+		v.Cursor = c
+		v.deselect(0)
+		v.skipFolds(-1)
+	}
+	v.Cursor = cursor
+	return true
+}
+
+// NextTab switches to the next tab of the editor that contains the view
+func (v *View) NextTab() bool {
+	if v.editor != nil && v.mainCursor() {
+		v.editor.NextTab()
+	}
+	return false
+}
+
+// PreviousTab switches to the previous tab of the editor that contains the
+// view
+func (v *View) PreviousTab() bool {
+	if v.editor != nil && v.mainCursor() {
+		v.editor.PreviousTab()
+	}
+	return false
+}
+
+// CloseTab closes the current tab of the editor that contains the view
+func (v *View) CloseTab() bool {
+	if v.editor != nil && v.mainCursor() {
+		v.editor.CloseTab()
+	}
+	return false
+}
+
+// MoveTabLeft moves the current tab of the editor that contains the view one
+// position to the left
+func (v *View) MoveTabLeft() bool {
+	if v.editor != nil && v.mainCursor() {
+		v.editor.MoveTab(-1)
+	}
+	return false
+}
+
+// MoveTabRight moves the current tab of the editor that contains the view one
+// position to the right
+func (v *View) MoveTabRight() bool {
+	if v.editor != nil && v.mainCursor() {
+		v.editor.MoveTab(1)
+	}
+	return false
+}
+
 // SelectAll selects the entire buffer
 func (v *View) SelectAll() bool {
 	v.Cursor.SetSelectionStart(v.Buf.Start())
@@ -897,11 +1112,7 @@ func (v *View) HalfPageDown() bool {
 // ToggleRuler turns line numbers off and on
 func (v *View) ToggleRuler() bool {
 	if v.mainCursor() {
-		if v.Buf.Settings["ruler"] == false {
-			v.Buf.Settings["ruler"] = true
-		} else {
-			v.Buf.Settings["ruler"] = false
-		}
+		v.Buf.Settings.Set("ruler", !v.Buf.Settings.Bool("ruler"))
 	}
 	return false
 }
@@ -75,6 +75,21 @@ const (
 	ActionRemoveAllMultiCursors  = "RemoveAllMultiCursors"
 	ActionSkipMultiCursor        = "SkipMultiCursor"
 	ActionJumpToMatchingBrace    = "JumpToMatchingBrace"
+	ActionDiffNext               = "DiffNext"
+	ActionDiffPrevious           = "DiffPrevious"
+	ActionDiffRevertHunk         = "DiffRevertHunk"
+	ActionConflictNext           = "ConflictNext"
+	ActionConflictTakeOurs       = "ConflictTakeOurs"
+	ActionConflictTakeTheirs     = "ConflictTakeTheirs"
+	ActionConflictTakeBoth       = "ConflictTakeBoth"
+	ActionFold                   = "Fold"
+	ActionUnfold                 = "Unfold"
+	ActionToggleAllFolds         = "ToggleAllFolds"
+	ActionNextTab                = "NextTab"
+	ActionPreviousTab            = "PreviousTab"
+	ActionCloseTab               = "CloseTab"
+	ActionMoveTabLeft            = "MoveTabLeft"
+	ActionMoveTabRight           = "MoveTabRight"
 	ActionInsertEnter            = "InsertEnter"
 	ActionUnbindKey              = "UnbindKey"
 )
@@ -201,6 +216,21 @@ var bindingActions = map[string]func(*View) bool{
 	ActionRemoveAllMultiCursors:  (*View).RemoveAllMultiCursors,
 	ActionSkipMultiCursor:        (*View).SkipMultiCursor,
 	ActionJumpToMatchingBrace:    (*View).JumpToMatchingBrace,
+	ActionDiffNext:               (*View).DiffNext,
+	ActionDiffPrevious:           (*View).DiffPrevious,
+	ActionDiffRevertHunk:         (*View).DiffRevertHunk,
+	ActionConflictNext:           (*View).ConflictNext,
+	ActionConflictTakeOurs:       (*View).ConflictTakeOurs,
+	ActionConflictTakeTheirs:     (*View).ConflictTakeTheirs,
+	ActionConflictTakeBoth:       (*View).ConflictTakeBoth,
+	ActionFold:                   (*View).Fold,
+	ActionUnfold:                 (*View).Unfold,
+	ActionToggleAllFolds:         (*View).ToggleAllFolds,
+	ActionNextTab:                (*View).NextTab,
+	ActionPreviousTab:            (*View).PreviousTab,
+	ActionCloseTab:               (*View).CloseTab,
+	ActionMoveTabLeft:            (*View).MoveTabLeft,
+	ActionMoveTabRight:           (*View).MoveTabRight,
 	ActionInsertEnter:            (*View).InsertNewline,
 }
 
@@ -400,6 +430,8 @@ func init() {
 		"Alt-p":          ActionRemoveMultiCursor,
 		"Alt-c":          ActionRemoveAllMultiCursors,
 		"Alt-x":          ActionSkipMultiCursor,
+		"Alt-,":          ActionPreviousTab,
+		"Alt-.":          ActionNextTab,
 	})
 }
 
@@ -1,8 +1,11 @@
 package femto
 
 import (
+	"bytes"
 	"crypto/md5"
 	"io"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"unicode/utf8"
 
@@ -30,9 +33,15 @@ type Buffer struct {
 	// The path to the loaded file, if any
 	Path string
 
-	Cursor    Cursor
-	cursors   []*Cursor // for multiple cursors
-	curCursor int       // the current cursor
+	// The cursors of the active view. Views make their cursors active with
+	// activateCursors when they handle an event or are drawn, so the active
+	// set changes whenever another view of the buffer does either. Code that
+	// uses the buffer's cursors outside of a view's HandleEvent, Draw or
+	// MouseHandler sees the cursors of the view that last called one of them.
+	*cursorSet
+	// The cursors of every view of the buffer. The first set belongs to the
+	// buffer itself and is used by the first view that opens it.
+	cursorSets []*cursorSet
 
 	// Name of the buffer on the status line
 	name string
@@ -50,7 +59,46 @@ type Buffer struct {
 	origHash [md5.Size]byte
 
 	// Buffer local settings
-	Settings map[string]interface{}
+	Settings *Settings
+
+	// The runtime files used to look up syntax definitions
+	runtimeFiles *RuntimeFiles
+
+	// Styled ranges drawn over the syntax highlighting
+	decorations      []*Decoration
+	lastDecorationID DecorationID
+
+	// Incremented every time the text of the buffer changes
+	revision int
+
+	// The text that the diff gutter compares the buffer against
+	diffBase    []string
+	hasDiffBase bool
+	diffHunks   []diffHunk
+
+	// The folded ranges of lines, sorted by their first line, and the ranges
+	// that can be folded when the foldmethod is manual
+	folds      []FoldRange
+	foldRanges []FoldRange
+
+	// The bracket nesting depth at the start of each line, computed with the
+	// given syntax definition
+	bracketDepths    []int
+	bracketDepthsDef *highlight.Def
+
+	// Cached merge conflict blocks
+	conflicts         []Conflict
+	conflictsRevision int
+
+	// Whether a UTF-16 file started with a byte order mark, which is then
+	// written again when it is saved
+	utf16BOM bool
+
+	// The raw contents of a binary buffer, which is edited in hex mode
+	binary  bool
+	data    []byte
+	hexUndo []hexEdit
+	hexRedo []hexEdit
 }
 
 // NewBufferFromString creates a new buffer containing the given string
@@ -61,52 +109,92 @@ func NewBufferFromString(text, path string) *Buffer {
 // NewBuffer creates a new buffer from a given reader
 func NewBuffer(reader io.Reader, size int64, path string, cursorPosition []string) *Buffer {
 	b := new(Buffer)
-	b.LineArray = NewLineArray(size, reader)
+
+	// The raw contents are kept until the character set of the file is
+	// known from the settings
+	data, _ := ioutil.ReadAll(reader)
+	sample := data
+	if len(sample) > binaryCheckSize {
+		sample = sample[:binaryCheckSize]
+	}
+	fileformat = 0
+	if isBinary(sample) {
+		// Binary files are kept as raw bytes so that they are saved unchanged
+		b.binary, b.data = true, data
+		b.LineArray = NewLineArray(0, strings.NewReader(""))
+	} else {
+		b.LineArray = NewLineArray(size, bytes.NewReader(data))
+	}
 
 	b.Settings = DefaultLocalSettings()
-	//	for k, v := range globalSettings {
-	//		if _, ok := b.Settings[k]; ok {
-	//			b.Settings[k] = v
-	//		}
-	//	}
+	b.Settings.OnChange(b.settingChanged)
+	b.applyGlobalSettings()
+
+	b.Path = path
+	if path != "" {
+		b.ApplyEditorConfig()
+	}
+
+	// Text in other character sets than UTF-8 may look binary, for example
+	// the NUL bytes of UTF-16
+	if text, ok := b.decodeText(data, b.binary); ok {
+		b.binary, b.data = false, nil
+		fileformat = 0
+		b.LineArray = NewLineArray(int64(len(text)), bytes.NewReader(text))
+	}
 
+	// The line endings of the file are kept even if the settings file asks
+	// for others, which only applies to new files
 	if fileformat == 1 {
-		b.Settings["fileformat"] = "unix"
+		b.Settings.SetFrom("fileformat", "unix", SourceFile)
 	} else if fileformat == 2 {
-		b.Settings["fileformat"] = "dos"
+		b.Settings.SetFrom("fileformat", "dos", SourceFile)
 	}
 
-	b.Path = path
-
 	b.EventHandler = NewEventHandler(b)
 
 	b.update()
+	b.detectIndentSettings()
+	b.ApplyModelines()
 
-	b.Cursor = Cursor{
-		Loc: Loc{0, 0},
-		buf: b,
-	}
+	b.cursorSet = b.newCursorSet(Loc{0, 0})
+	b.cursorSets = []*cursorSet{b.cursorSet}
 
 	//InitLocalSettings(b)
 
-	if !b.Settings["fastdirty"].(bool) {
+	if !b.Settings.Bool("fastdirty") {
 		if size > LargeFileThreshold {
 			// If the file is larger than a megabyte fastdirty needs to be on
-			b.Settings["fastdirty"] = true
+			b.Settings.SetFrom("fastdirty", true, SourceFile)
 		} else {
 			calcHash(b, &b.origHash)
 		}
 	}
 
-	b.cursors = []*Cursor{&b.Cursor}
+	b.diffBaseChanged()
 
 	return b
 }
 
 // GetName returns the name that should be displayed in the statusline
-// for this buffer
+// for this buffer. This is the name set with SetName, or the buffer's path if
+// there is none.
 func (b *Buffer) GetName() string {
-	return b.name
+	switch {
+	case b.name != "":
+		return b.name
+	case b.Path == "":
+		return "No name"
+	case b.Settings.Bool("basename"):
+		return filepath.Base(b.Path)
+	default:
+		return b.Path
+	}
+}
+
+// SetName sets the name that is displayed in the statusline for this buffer
+func (b *Buffer) SetName(name string) {
+	b.name = name
 }
 
 // updateRules updates the syntax rules and filetype for this buffer
@@ -115,6 +203,7 @@ func (b *Buffer) updateRules(runtimeFiles *RuntimeFiles) {
 	if runtimeFiles == nil {
 		return
 	}
+	b.runtimeFiles = runtimeFiles
 
 	rehighlight := false
 	var files []*highlight.File
@@ -130,7 +219,7 @@ func (b *Buffer) updateRules(runtimeFiles *RuntimeFiles) {
 				continue
 			}
 
-			ft := b.Settings["filetype"].(string)
+			ft := b.Settings.String("filetype")
 			if (ft == "Unknown" || ft == "") && !rehighlight {
 				if highlight.MatchFiletype(ftdetect, b.Path, b.lines[0].data) {
 					header := new(highlight.Header)
@@ -164,24 +253,52 @@ func (b *Buffer) updateRules(runtimeFiles *RuntimeFiles) {
 
 	if b.highlighter == nil || rehighlight {
 		if b.syntaxDef != nil {
-			b.Settings["filetype"] = b.syntaxDef.FileType
+			b.Settings.SetFrom("filetype", b.syntaxDef.FileType, SourceDetected)
 			b.highlighter = highlight.NewHighlighter(b.syntaxDef)
-			if b.Settings["syntax"].(bool) {
+			if b.Settings.Bool("syntax") {
 				b.highlighter.HighlightStates(b)
 			}
 		}
 	}
 }
 
+// settingChanged is called whenever one of the buffer's options changes
+func (b *Buffer) settingChanged(name string, old, new interface{}) {
+	switch name {
+	case "detectindent":
+		b.detectIndentSettings()
+	case "modelines", "modelinelines", "modelineoptions":
+		b.ApplyModelines()
+	case "diffgutter":
+		b.diffBaseChanged()
+	case "fastdirty":
+		// The hash is only calculated while fastdirty is off. It can be
+		// calculated now if the buffer has not been edited yet.
+		if !new.(bool) && !b.IsModified && b.LineArray != nil {
+			calcHash(b, &b.origHash)
+		}
+	case "filetype":
+		b.applyFiletypeSettings(new.(string))
+
+		// Changes made by updateRules itself are already in effect
+		if b.syntaxDef != nil && b.syntaxDef.FileType == new {
+			return
+		}
+		b.syntaxDef, b.highlighter = nil, nil
+		b.ClearMatches()
+		b.updateRules(b.runtimeFiles)
+	}
+}
+
 // FileType returns the buffer's filetype
 func (b *Buffer) FileType() string {
-	return b.Settings["filetype"].(string)
+	return b.Settings.String("filetype")
 }
 
 // IndentString returns a string representing one level of indentation
 func (b *Buffer) IndentString() string {
-	if b.Settings["tabstospaces"].(bool) {
-		return Spaces(int(b.Settings["tabsize"].(float64)))
+	if b.Settings.Bool("tabstospaces") {
+		return Spaces(b.Settings.Int("tabsize"))
 	}
 	return "\t"
 }
@@ -230,7 +347,9 @@ func (b *Buffer) UpdateCursors() {
 func calcHash(b *Buffer, out *[md5.Size]byte) {
 	h := md5.New()
 
-	if len(b.lines) > 0 {
+	if b.binary {
+		h.Write(b.data)
+	} else if len(b.lines) > 0 {
 		h.Write(b.lines[0].data)
 
 		for _, l := range b.lines[1:] {
@@ -245,7 +364,7 @@ func calcHash(b *Buffer, out *[md5.Size]byte) {
 // Modified returns if this buffer has been modified since
 // being opened
 func (b *Buffer) Modified() bool {
-	if b.Settings["fastdirty"].(bool) {
+	if b.Settings.Bool("fastdirty") {
 		return b.IsModified
 	}
 
@@ -257,13 +376,26 @@ func (b *Buffer) Modified() bool {
 
 func (b *Buffer) insert(pos Loc, value []byte) {
 	b.IsModified = true
+	b.revision++
 	b.LineArray.insert(pos, value)
 	b.update()
+	end := insertEnd(pos, value)
+	b.updateDecorationsForInsert(pos, end)
+	b.updateFoldsForEdit(pos.Y, pos.Y, end.Y)
+	b.updateDiffForEdit(pos.Y, pos.Y, end.Y)
+	b.moveInactiveCursorsForInsert(pos, end)
+	b.invalidateBracketDepths(pos.Y)
 }
 func (b *Buffer) remove(start, end Loc) string {
 	b.IsModified = true
+	b.revision++
 	sub := b.LineArray.remove(start, end)
 	b.update()
+	b.updateDecorationsForRemove(start, end)
+	b.updateFoldsForEdit(start.Y, end.Y, start.Y)
+	b.updateDiffForEdit(start.Y, end.Y, start.Y)
+	b.moveInactiveCursorsForRemove(start, end)
+	b.invalidateBracketDepths(start.Y)
 	return sub
 }
 func (b *Buffer) deleteToEnd(start Loc) {
@@ -1,6 +1,8 @@
 package femto
 
 import (
+	"unicode/utf8"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
 )
@@ -49,6 +51,25 @@ func visualToCharPos(visualIndex int, lineN int, str string, buf *Buffer, colors
 	return -1, -1, style
 }
 
+// whitespaceGlyph returns the character that is drawn for a kind of
+// whitespace, as given by the option with the given name
+func whitespaceGlyph(buf *Buffer, option string) rune {
+	r, _ := utf8.DecodeRuneInString(buf.Settings.String(option))
+	if runewidth.RuneWidth(r) != 1 {
+		return ' '
+	}
+	return r
+}
+
+// whitespaceStyle returns the style used to draw visible whitespace over the
+// given style
+func whitespaceStyle(colorscheme Colorscheme, base tcell.Style) tcell.Style {
+	if style, ok := colorscheme["whitespace"]; ok {
+		return mergeStyle(base, style)
+	}
+	return base.Dim(true)
+}
+
 type Char struct {
 	visualLoc Loc
 	realLoc   Loc
@@ -62,6 +83,21 @@ type Char struct {
 
 type CellView struct {
 	lines [][]*Char
+
+	// The buffer line displayed on each row, or -1 for filler rows
+	rowLines []int
+	// The number of columns of wrap indentation at the start of each row
+	rowIndents []int
+	// The width of the indentation that gets indent guides on each row
+	rowGuides []int
+	// The indent guide of the block that contains the cursor
+	activeGuide indentGuide
+
+	// The number of empty filler rows displayed above each line, used to
+	// align the lines of two views
+	fillers map[int]int
+	// The number of filler rows above the top line that are scrolled out of view
+	fillerSkip int
 }
 
 func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left, width int) {
@@ -71,11 +107,11 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 
 	matchingBrace := Loc{-1, -1}
 	// bracePairs is defined in buffer.go
-	if buf.Settings["matchbrace"].(bool) {
+	if buf.Settings.Bool("matchbrace") {
 		for _, bp := range bracePairs {
 			curX := buf.Cursor.X
 			curLoc := buf.Cursor.Loc
-			if buf.Settings["matchbraceleft"].(bool) {
+			if buf.Settings.Bool("matchbraceleft") {
 				if curX > 0 {
 					curX--
 					curLoc = curLoc.Move(-1, buf)
@@ -89,17 +125,39 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 		}
 	}
 
-	tabsize := int(buf.Settings["tabsize"].(float64))
-	softwrap := buf.Settings["softwrap"].(bool)
-	indentrunes := []rune(buf.Settings["indentchar"].(string))
+	tabsize := buf.Settings.Int("tabsize")
+	softwrap := buf.Settings.Bool("softwrap")
+	indentrunes := []rune(buf.Settings.String("indentchar"))
 	// if empty indentchar settings, use space
 	if indentrunes == nil || len(indentrunes) == 0 {
 		indentrunes = []rune{' '}
 	}
 	indentchar := indentrunes[0]
 
+	showTrailing := buf.Settings.Bool("showtrailingws")
+	trailingChar := whitespaceGlyph(buf, "trailingwschar")
+	showNbsp := buf.Settings.Bool("shownbsp")
+	nbspChar := whitespaceGlyph(buf, "nbspchar")
+
+	guides := buf.Settings.Bool("indentguides")
+	guideChar := whitespaceGlyph(buf, "indentguidechar")
+	c.activeGuide = indentGuide{col: -1}
+	if guides {
+		c.activeGuide = buf.activeIndentGuide(tabsize)
+	}
+
+	rainbow := buf.Settings.Bool("rainbowbrackets")
+	var rainbowStyles []tcell.Style
+	if rainbow {
+		rainbowStyles = bracketStyles(colorscheme)
+	}
+
+	// The line after the last line that can be displayed, taking folds into
+	// account
+	bottom := buf.lineAfterRows(top, height)
+
 	start := buf.Cursor.Y
-	if buf.Settings["syntax"].(bool) && buf.syntaxDef != nil {
+	if buf.Settings.Bool("syntax") && buf.syntaxDef != nil {
 		if start > 0 && buf.lines[start-1].rehighlight {
 			buf.highlighter.ReHighlightLine(buf, start-1)
 			buf.lines[start-1].rehighlight = false
@@ -107,22 +165,50 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 
 		buf.highlighter.ReHighlightStates(buf, start)
 
-		buf.highlighter.HighlightMatches(buf, top, top+height)
+		buf.highlighter.HighlightMatches(buf, top, bottom)
 	}
 
 	c.lines = make([][]*Char, 0)
+	c.rowLines = c.rowLines[:0]
+	c.rowIndents = c.rowIndents[:0]
+	c.rowGuides = c.rowGuides[:0]
 
 	viewLine := 0
 	lineN := top
 
-	curStyle := defStyle
+	curStyle := colorscheme.defaultStyle()
 	for viewLine < height {
-		if lineN >= len(buf.lines) {
+		if lineN > len(buf.lines) {
+			break
+		}
+
+		fillers := c.fillers[lineN]
+		if lineN == top {
+			fillers -= c.fillerSkip
+		}
+		for ; fillers > 0 && viewLine < height; fillers-- {
+			c.lines = append(c.lines, nil)
+			c.rowLines = append(c.rowLines, -1)
+			c.rowIndents = append(c.rowIndents, 0)
+			c.rowGuides = append(c.rowGuides, 0)
+			viewLine++
+		}
+		if lineN >= len(buf.lines) || viewLine >= height {
 			break
 		}
 
 		lineStr := buf.Line(lineN)
 		line := []rune(lineStr)
+		var bracketLevels map[int]int
+		if rainbow {
+			bracketLevels = make(map[int]int)
+			buf.scanBrackets(lineN, buf.bracketDepthAt(lineN), bracketLevels)
+		}
+
+		trailingStart := len(line)
+		for trailingStart > 0 && (line[trailingStart-1] == ' ' || line[trailingStart-1] == '\t') {
+			trailingStart--
+		}
 
 		colN, startOffset, startStyle := visualToCharPos(left, lineN, lineStr, buf, colorscheme, tabsize)
 		if colN < 0 {
@@ -136,15 +222,28 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 		// We'll either draw the length of the line, or the width of the screen
 		// whichever is smaller
 		lineLength := min(StringWidth(lineStr, tabsize), width)
-		c.lines = append(c.lines, make([]*Char, lineLength))
 
-		wrap := false
-		// We only need to wrap if the length of the line is greater than the width of the terminal screen
-		if softwrap && StringWidth(lineStr, tabsize) > width {
-			wrap = true
-			// We're going to draw the entire line now
-			lineLength = StringWidth(lineStr, tabsize)
+		var rows []wrapRow
+		if softwrap {
+			rows = buf.wrapLine(lineN, width)
 		}
+		// We only need to wrap if the line does not fit on one row
+		wrap := len(rows) > 1
+		if wrap {
+			lineLength = width
+		}
+		row := 0
+
+		guideIndent := 0
+		if guides {
+			guideIndent = buf.guideIndent(lineN, tabsize)
+		}
+
+		firstRow := viewLine
+		c.lines = append(c.lines, make([]*Char, lineLength))
+		c.rowLines = append(c.rowLines, lineN)
+		c.rowIndents = append(c.rowIndents, 0)
+		c.rowGuides = append(c.rowGuides, guideIndent)
 
 		for viewCol < lineLength {
 			if colN >= len(line) {
@@ -158,11 +257,21 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 
 			if viewCol >= 0 {
 				st := curStyle
+				if level, ok := bracketLevels[colN]; ok {
+					st = mergeStyle(st, rainbowStyles[level%len(rainbowStyles)])
+				}
 				if colN == matchingBrace.X && lineN == matchingBrace.Y && !buf.Cursor.HasSelection() {
 					st = curStyle.Reverse(true)
 				}
+				drawChar := char
+				switch {
+				case showTrailing && colN >= trailingStart && char == ' ':
+					drawChar, st = trailingChar, whitespaceStyle(colorscheme, st)
+				case showNbsp && char == '\u00a0':
+					drawChar, st = nbspChar, whitespaceStyle(colorscheme, st)
+				}
 				if viewCol < len(c.lines[viewLine]) {
-					c.lines[viewLine][viewCol] = &Char{Loc{viewCol, viewLine}, Loc{colN, lineN}, char, char, st, 1}
+					c.lines[viewLine][viewCol] = &Char{Loc{viewCol, viewLine}, Loc{colN, lineN}, char, drawChar, st, 1}
 				}
 			}
 			if char == '\t' {
@@ -172,10 +281,13 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 					c.lines[viewLine][viewCol].width = charWidth
 
 					indentStyle := curStyle
-					ch := buf.Settings["indentchar"].(string)
+					ch := buf.Settings.String("indentchar")
 					if group, ok := colorscheme["indent-char"]; ok && !IsStrWhitespace(ch) && ch != "" {
 						indentStyle = group
 					}
+					if showTrailing && colN >= trailingStart {
+						indentStyle = whitespaceStyle(colorscheme, indentStyle)
+					}
 
 					c.lines[viewLine][viewCol].style = indentStyle
 				}
@@ -204,7 +316,8 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 			}
 			colN++
 
-			if wrap && viewCol >= width {
+			if wrap && row+1 < len(rows) && colN == rows[row+1].start {
+				row++
 				viewLine++
 
 				// If we go too far soft wrapping we have to cut off
@@ -212,11 +325,12 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 					break
 				}
 
-				nextLine := line[colN:]
-				lineLength := min(StringWidth(string(nextLine), tabsize), width)
 				c.lines = append(c.lines, make([]*Char, lineLength))
+				c.rowLines = append(c.rowLines, lineN)
+				c.rowIndents = append(c.rowIndents, rows[row].indent)
+				c.rowGuides = append(c.rowGuides, 0)
 
-				viewCol = 0
+				viewCol = rows[row].indent
 			}
 
 		}
@@ -224,12 +338,27 @@ func (c *CellView) Draw(buf *Buffer, colorscheme Colorscheme, top, height, left,
 			curStyle = colorscheme.GetColor(group.String())
 		}
 
+		if guideIndent > 0 {
+			// Draw the guides over the spaces of the leading whitespace.
+			// Tabs that are displayed with a visible indentchar are kept.
+			leading := Count(GetLeadingWhitespace(lineStr))
+			for _, ch := range c.lines[firstRow] {
+				if ch == nil || ch.realLoc.X >= leading || ch.drawChar != ' ' {
+					continue
+				}
+				if col := ch.visualLoc.X + left; col%tabsize == 0 && col < guideIndent {
+					ch.drawChar = guideChar
+					ch.style = indentGuideStyle(colorscheme, ch.style, c.activeGuide.contains(col, lineN))
+				}
+			}
+		}
+
 		// newline
 		viewLine++
-		lineN++
+		lineN = buf.nextVisibleLine(lineN)
 	}
 
-	for i := top; i < top+height; i++ {
+	for i := top; i < bottom; i++ {
 		if i >= buf.NumLines {
 			break
 		}
@@ -13,12 +13,16 @@ import (
 )
 
 func saveBuffer(b *femto.Buffer, path string) error {
-	return ioutil.WriteFile(path, []byte(b.String()), 0600)
+	return ioutil.WriteFile(path, b.Bytes(), 0600)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import-theme" {
+		importTheme(os.Args[2:])
+		return
+	}
 	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "usage: femto [filename]\n")
+		fmt.Fprintf(os.Stderr, "usage: femto [filename]\n       femto import-theme theme.json|theme.tmTheme [output.micro]\n")
 		os.Exit(1)
 	}
 	path := os.Args[1]
@@ -28,18 +32,21 @@ func main() {
 		log.Fatalf("could not read %v: %v", path, err)
 	}
 
-	var colorscheme femto.Colorscheme
-	if monokai := runtime.Files.FindFile(femto.RTColorscheme, "monokai"); monokai != nil {
-		if data, err := monokai.Data(); err == nil {
-			colorscheme = femto.ParseColorscheme(string(data))
-		}
+	colorscheme, warnings, err := femto.LoadColorscheme(runtime.Files, "monokai")
+	for _, w := range warnings {
+		log.Printf("colorscheme: %v", w)
+	}
+	if err != nil {
+		log.Printf("could not load colorscheme: %v", err)
 	}
 
 	app := tview.NewApplication()
 	buffer := femto.NewBufferFromString(string(content), path)
+	buffer.Settings.Set("statusline", true)
 	root := femto.NewView(buffer)
 	root.SetRuntimeFiles(runtime.Files)
 	root.SetColorscheme(colorscheme)
+	root.SetHelpText("Ctrl-s: save, Ctrl-q: quit")
 	root.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
 		case tcell.KeyCtrlS:
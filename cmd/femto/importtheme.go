@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pgavlin/femto"
+)
+
+// importTheme converts a VS Code or TextMate theme to a .micro colorscheme,
+// which is written to the given output file or to stdout
+func importTheme(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: femto import-theme theme.json|theme.tmTheme [output.micro]\n")
+		os.Exit(1)
+	}
+	path := args[0]
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("could not read %v: %v", path, err)
+	}
+
+	parse := femto.ParseVSCodeTheme
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tmtheme", ".plist":
+		parse = femto.ParseTmTheme
+	}
+	colorscheme, err := parse(data)
+	if colorscheme == nil {
+		log.Fatalf("could not import %v: %v", path, err)
+	}
+	if err != nil {
+		log.Printf("%v: %v", path, err)
+	}
+
+	out := os.Stdout
+	if len(args) == 2 {
+		if out, err = os.Create(args[1]); err != nil {
+			log.Fatalf("could not create %v: %v", args[1], err)
+		}
+	}
+	w := bufio.NewWriter(out)
+	if err := femto.WriteColorscheme(w, colorscheme); err != nil {
+		log.Fatalf("could not write colorscheme: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("could not write colorscheme: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("could not write colorscheme: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package femto
+
+import "github.com/gdamore/tcell/v2"
+
+// trueColors is the number of colors reported by screens that support 24-bit
+// color
+const trueColors = 1 << 24
+
+// colorPalette returns the colors that a terminal with the given number of
+// colors displays reliably. The first 16 colors of a 256 color terminal are
+// left out because terminal themes often change them.
+func colorPalette(colors int) []tcell.Color {
+	start := 0
+	if colors >= 256 {
+		start, colors = 16, 256
+	}
+
+	palette := make([]tcell.Color, 0, colors-start)
+	for i := start; i < colors; i++ {
+		palette = append(palette, tcell.PaletteColor(i))
+	}
+	return palette
+}
+
+// Downsample returns a copy of the colorscheme in which each color that a
+// terminal with the given number of colors cannot display is replaced by the
+// nearest color it can display. The colorscheme is returned unchanged for
+// terminals that support 24-bit color or no color at all.
+func (colorscheme Colorscheme) Downsample(colors int) Colorscheme {
+	if colors >= trueColors || colors <= 0 {
+		return colorscheme
+	}
+
+	palette := colorPalette(colors)
+	fitted := make(map[tcell.Color]tcell.Color)
+	fit := func(c tcell.Color) tcell.Color {
+		if !c.Valid() || !c.IsRGB() && int(c-tcell.ColorValid) < colors {
+			return c
+		}
+		if f, ok := fitted[c]; ok {
+			return f
+		}
+		f := tcell.FindColor(c, palette)
+		fitted[c] = f
+		return f
+	}
+
+	downsampled := make(Colorscheme, len(colorscheme))
+	for group, style := range colorscheme {
+		fg, bg, _ := style.Decompose()
+		downsampled[group] = style.Foreground(fit(fg)).Background(fit(bg))
+	}
+	return downsampled
+}
+
+// colorDepth returns the number of colors that the view is drawn with, which
+// is the number of colors the screen supports unless the colordepth option
+// overrides it
+func (v *View) colorDepth(screen tcell.Screen) int {
+	switch v.Buf.Settings.String("colordepth") {
+	case "8":
+		return 8
+	case "16":
+		return 16
+	case "256":
+		return 256
+	case "truecolor":
+		return trueColors
+	}
+	return screen.Colors()
+}
+
+// updateColorDepth downsamples the view's colorscheme to the colors that it
+// is drawn with
+func (v *View) updateColorDepth(screen tcell.Screen) {
+	colors := v.colorDepth(screen)
+	if colors == v.colors {
+		return
+	}
+	v.colors = colors
+	v.colorscheme = v.fullColorscheme.Downsample(colors)
+	v.minimapColors = nil
+}
@@ -25,7 +25,7 @@ func GetColor(color string) tcell.Style {
 
 // GetColor takes in a syntax group and returns the colorscheme's style for that group
 func (colorscheme Colorscheme) GetColor(color string) tcell.Style {
-	st := defStyle
+	st := colorscheme.defaultStyle()
 	if color == "" {
 		return st
 	}
@@ -50,6 +50,15 @@ func (colorscheme Colorscheme) GetColor(color string) tcell.Style {
 	return st
 }
 
+// defaultStyle returns the style of the colorscheme's default group, or the
+// default cell style if it has none
+func (colorscheme Colorscheme) defaultStyle() tcell.Style {
+	if style, ok := colorscheme["default"]; ok {
+		return style
+	}
+	return defStyle
+}
+
 // init picks and initializes the colorscheme when micro starts
 func init() {
 	colorscheme = make(Colorscheme)
@@ -63,86 +72,187 @@ func SetDefaultColorscheme(scheme Colorscheme) {
 	colorscheme = scheme
 }
 
+// colorLinkParser matches a color-link statement, which links a color group
+// to a style
+var colorLinkParser = regexp.MustCompile(`^color-link\s+(\S*)\s+"(.*)"$`)
+
+// emptyLinkParser matches a color-link statement without a style
+var emptyLinkParser = regexp.MustCompile(`^color-link\s+(\S*)$`)
+
+// includeParser matches an include statement, which includes the statements
+// of another colorscheme
+var includeParser = regexp.MustCompile(`^include\s+"(.*)"$`)
+
+// LoadColorscheme finds the colorscheme with the given name in the runtime
+// files and parses it with ParseColorschemeWithIncludes.
+func LoadColorscheme(runtimeFiles *RuntimeFiles, name string) (Colorscheme, []error, error) {
+	c := make(Colorscheme)
+	warnings, err := parseColorscheme(c, runtimeFiles, name, map[string]bool{})
+	return c, warnings, err
+}
+
 // ParseColorscheme parses the text definition for a colorscheme and returns the corresponding object
 // Colorschemes are made up of color-link statements linking a color group to a list of colors
 // For example, color-link keyword (blue,red) makes all keywords have a blue foreground and
 // red background
+// Invalid statements are ignored. Use ParseColorschemeWithIncludes to find out about them, or
+// to parse colorschemes that include other colorschemes.
 func ParseColorscheme(text string) Colorscheme {
-	parser := regexp.MustCompile(`color-link\s+(\S*)\s+"(.*)"`)
-
-	lines := strings.Split(text, "\n")
+	c, _, _ := ParseColorschemeWithIncludes(text, nil)
+	return c
+}
 
+// ParseColorschemeWithIncludes parses a colorscheme like ParseColorscheme. A colorscheme can
+// build on another one with an include "name" statement. The included colorscheme is looked
+// up in the given runtime files, and its color-links can be overridden by the statements after
+// the include.
+// Colors that are not known and color-links without a style are reported as warnings. The
+// colors are replaced by the default colors, and the color-links are skipped. If a statement
+// is invalid or an included colorscheme cannot be loaded, the colorscheme is returned with the
+// valid statements along with an error that describes the first problem.
+func ParseColorschemeWithIncludes(text string, runtimeFiles *RuntimeFiles) (Colorscheme, []error, error) {
 	c := make(Colorscheme)
+	warnings, err := parseColorschemeText(c, runtimeFiles, text, map[string]bool{})
+	return c, warnings, err
+}
+
+// parseColorscheme adds the color-links of the colorscheme with the given
+// name to c. including holds the names of the colorschemes that are being
+// included, which are not allowed to include themselves again.
+func parseColorscheme(c Colorscheme, runtimeFiles *RuntimeFiles, name string, including map[string]bool) ([]error, error) {
+	if including[name] {
+		return nil, fmt.Errorf("colorscheme %q includes itself", name)
+	}
+
+	var file RuntimeFile
+	if runtimeFiles != nil {
+		file = runtimeFiles.FindFile(RTColorscheme, name)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("colorscheme %q not found", name)
+	}
+	data, err := file.Data()
+	if err != nil {
+		return nil, fmt.Errorf("reading colorscheme %q: %v", name, err)
+	}
+
+	including[name] = true
+	defer delete(including, name)
+	warnings, err := parseColorschemeText(c, runtimeFiles, string(data), including)
+	for i, w := range warnings {
+		warnings[i] = fmt.Errorf("colorscheme %q: %v", name, w)
+	}
+	if err != nil {
+		err = fmt.Errorf("colorscheme %q: %v", name, err)
+	}
+	return warnings, err
+}
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" ||
-			strings.TrimSpace(line)[0] == '#' {
+// parseColorschemeText adds the color-links of a colorscheme definition to c
+func parseColorschemeText(c Colorscheme, runtimeFiles *RuntimeFiles, text string, including map[string]bool) ([]error, error) {
+	var warnings []error
+	var firstErr error
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
 			// Ignore this line
 			continue
 		}
 
-		matches := parser.FindSubmatch([]byte(line))
-		if len(matches) == 3 {
-			link := string(matches[1])
-			colors := string(matches[2])
+		var err error
+		if matches := colorLinkParser.FindStringSubmatch(line); matches != nil {
+			link, colors := matches[1], matches[2]
 
-			style := StringToStyle(colors)
+			style, warning := parseStyle(colors)
+			if warning != nil {
+				warnings = append(warnings, fmt.Errorf("line %d: %v", i+1, warning))
+			}
 			c[link] = style
 
 			if link == "default" {
 				defStyle = style
 			}
+		} else if matches := emptyLinkParser.FindStringSubmatch(line); matches != nil {
+			warnings = append(warnings, fmt.Errorf("line %d: color-link %s has no style", i+1, matches[1]))
+		} else if matches := includeParser.FindStringSubmatch(line); matches != nil {
+			var included []error
+			included, err = parseColorscheme(c, runtimeFiles, matches[1], including)
+			warnings = append(warnings, included...)
 		} else {
-			fmt.Println("Color-link statement is not valid:", line)
+			err = fmt.Errorf("invalid statement %q", line)
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("line %d: %v", i+1, err)
 		}
 	}
+	return warnings, firstErr
+}
 
-	return c
+// styleAttributes maps the names of text attributes to the functions that
+// set them on a style
+var styleAttributes = map[string]func(tcell.Style, bool) tcell.Style{
+	"bold":          tcell.Style.Bold,
+	"italic":        tcell.Style.Italic,
+	"dim":           tcell.Style.Dim,
+	"blink":         tcell.Style.Blink,
+	"reverse":       tcell.Style.Reverse,
+	"underline":     tcell.Style.Underline,
+	"strikethrough": tcell.Style.StrikeThrough,
 }
 
 // StringToStyle returns a style from a string
 // The strings must be in the format "extra foregroundcolor,backgroundcolor"
-// The 'extra' can be bold, reverse, or underline
+// The 'extra' can be any of bold, italic, dim, blink, reverse, underline or
+// strikethrough, separated by spaces, and may also come after the colors
+// Invalid colors are ignored
 func StringToStyle(str string) tcell.Style {
-	var fg, bg string
-	spaceSplit := strings.Split(str, " ")
-	var split []string
-	if len(spaceSplit) > 1 {
-		split = strings.Split(spaceSplit[1], ",")
-	} else {
-		split = strings.Split(str, ",")
+	style, _ := parseStyle(str)
+	return style
+}
+
+// parseStyle returns a style from a string in the format accepted by
+// StringToStyle, and an error if it contains invalid colors
+func parseStyle(str string) (tcell.Style, error) {
+	var err error
+
+	// The words that are not attributes are the colors, which may be
+	// separated by spaces as well as the comma
+	var attrs, colorFields []string
+	for _, field := range strings.Fields(str) {
+		if _, ok := styleAttributes[field]; ok {
+			attrs = append(attrs, field)
+		} else {
+			colorFields = append(colorFields, field)
+		}
 	}
+	colors := strings.Join(colorFields, "")
+
+	var fg, bg string
+	split := strings.Split(colors, ",")
 	if len(split) > 1 {
 		fg, bg = split[0], split[1]
 	} else {
 		fg = split[0]
 	}
-	fg = strings.TrimSpace(fg)
-	bg = strings.TrimSpace(bg)
 
-	var fgColor, bgColor tcell.Color
-	if fg == "" {
-		fgColor, _, _ = defStyle.Decompose()
-	} else {
-		fgColor = StringToColor(fg)
-	}
-	if bg == "" {
-		_, bgColor, _ = defStyle.Decompose()
-	} else {
-		bgColor = StringToColor(bg)
+	color := func(name string, def tcell.Color) tcell.Color {
+		if name == "" {
+			return def
+		}
+		c := StringToColor(name)
+		if c == tcell.ColorDefault && name != "default" && err == nil {
+			err = fmt.Errorf("invalid color %q", name)
+		}
+		return c
 	}
+	defFg, defBg, _ := defStyle.Decompose()
+	style := defStyle.Foreground(color(fg, defFg)).Background(color(bg, defBg))
 
-	style := defStyle.Foreground(fgColor).Background(bgColor)
-	if strings.Contains(str, "bold") {
-		style = style.Bold(true)
-	}
-	if strings.Contains(str, "reverse") {
-		style = style.Reverse(true)
+	for _, attr := range attrs {
+		style = styleAttributes[attr](style, true)
 	}
-	if strings.Contains(str, "underline") {
-		style = style.Underline(true)
-	}
-	return style
+	return style, err
 }
 
 // StringToColor returns a tcell color from a string representation of a color
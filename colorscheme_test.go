@@ -0,0 +1,102 @@
+package femto_test
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pgavlin/femto"
+	"github.com/pgavlin/femto/runtime"
+)
+
+func TestParseColorscheme(t *testing.T) {
+	white, black := femto.StringToColor("#ffffff"), femto.StringToColor("#000000")
+
+	// Parsing a default color-link changes the style that missing colors
+	// fall back to, so the style tests start from the terminal defaults
+	const reset = "color-link default \"default,default\"\n"
+
+	cases := []struct {
+		name string
+		text string
+
+		// The expected style of the "test" group, if any
+		fg, bg tcell.Color
+		attrs  tcell.AttrMask
+	}{
+		{name: "atom-dark-tc", text: `include "atom-dark-tc"`},
+		{name: "bubblegum", text: `include "bubblegum"`},
+		{name: "cmc-16", text: `include "cmc-16"`},
+		{name: "cmc-paper", text: `include "cmc-paper"`},
+		{name: "cmc-tc", text: `include "cmc-tc"`},
+		{name: "darcula", text: `include "darcula"`},
+		{name: "default", text: `include "default"`},
+		{name: "geany", text: `include "geany"`},
+		{name: "github-tc", text: `include "github-tc"`},
+		{name: "gruvbox-tc", text: `include "gruvbox-tc"`},
+		{name: "gruvbox", text: `include "gruvbox"`},
+		{name: "material-tc", text: `include "material-tc"`},
+		{name: "monokai", text: `include "monokai"`},
+		{name: "railscast", text: `include "railscast"`},
+		{name: "simple", text: `include "simple"`},
+		{name: "solarized-tc", text: `include "solarized-tc"`},
+		{name: "solarized", text: `include "solarized"`},
+		{name: "twilight", text: `include "twilight"`},
+		{name: "zenburn", text: `include "zenburn"`},
+		{
+			name:  "attribute before colors",
+			text:  reset + `color-link test "bold #ffffff,#000000"`,
+			fg:    white,
+			bg:    black,
+			attrs: tcell.AttrBold,
+		},
+		{
+			name:  "attribute after colors",
+			text:  reset + `color-link test "#ffffff,#000000 bold"`,
+			fg:    white,
+			bg:    black,
+			attrs: tcell.AttrBold,
+		},
+		{
+			name:  "attributes around colors",
+			text:  reset + `color-link test "italic #ffffff,#000000 underline"`,
+			fg:    white,
+			bg:    black,
+			attrs: tcell.AttrItalic | tcell.AttrUnderline,
+		},
+		{
+			name:  "space after comma",
+			text:  reset + `color-link test "underline blue, white"`,
+			fg:    tcell.ColorNavy,
+			bg:    tcell.ColorSilver,
+			attrs: tcell.AttrUnderline,
+		},
+		{
+			name:  "space before comma",
+			text:  reset + `color-link test "bold ,brightred"`,
+			fg:    tcell.ColorDefault,
+			bg:    tcell.ColorRed,
+			attrs: tcell.AttrBold,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			colorscheme, _, err := femto.ParseColorschemeWithIncludes(c.text, runtime.Files)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(colorscheme) == 0 {
+				t.Fatalf("colorscheme is empty")
+			}
+
+			style, ok := colorscheme["test"]
+			if !ok {
+				return
+			}
+			fg, bg, attrs := style.Decompose()
+			if fg != c.fg || bg != c.bg || attrs != c.attrs {
+				t.Errorf("got (%v, %v, %v), want (%v, %v, %v)", fg, bg, attrs, c.fg, c.bg, c.attrs)
+			}
+		})
+	}
+}
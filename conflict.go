@@ -0,0 +1,151 @@
+package femto
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A Conflict is a block of merge conflict markers in a buffer:
+//
+//	<<<<<<< ours
+//	our lines
+//	||||||| base (optional)
+//	base lines
+//	=======
+//	their lines
+//	>>>>>>> theirs
+//
+// The fields hold the line numbers of each marker. Base is -1 if the block
+// does not contain the optional base section.
+type Conflict struct {
+	Start     int
+	Base      int
+	Separator int
+	End       int
+}
+
+// Ours returns the range [start, end) of lines on our side of the conflict
+func (c Conflict) Ours() (int, int) {
+	if c.Base >= 0 {
+		return c.Start + 1, c.Base
+	}
+	return c.Start + 1, c.Separator
+}
+
+// Theirs returns the range [start, end) of lines on their side of the conflict
+func (c Conflict) Theirs() (int, int) {
+	return c.Separator + 1, c.End
+}
+
+// isConflictMarker returns true if the line starts with the given conflict
+// marker. Markers other than the separator may be followed by a label.
+func isConflictMarker(line, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+	rest := line[len(marker):]
+	if marker == "=======" {
+		return strings.TrimSpace(rest) == ""
+	}
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// Conflicts returns the merge conflict blocks in the buffer
+func (b *Buffer) Conflicts() []Conflict {
+	if b.conflictsRevision == b.revision && b.conflicts != nil {
+		return b.conflicts
+	}
+
+	conflicts := []Conflict{}
+	c := Conflict{Start: -1, Base: -1, Separator: -1}
+	for i := 0; i < b.NumLines; i++ {
+		line := b.Line(i)
+		switch {
+		case isConflictMarker(line, "<<<<<<<"):
+			c = Conflict{Start: i, Base: -1, Separator: -1}
+		case c.Start < 0:
+			continue
+		case isConflictMarker(line, "|||||||") && c.Base < 0 && c.Separator < 0:
+			c.Base = i
+		case isConflictMarker(line, "=======") && c.Separator < 0:
+			c.Separator = i
+		case isConflictMarker(line, ">>>>>>>") && c.Separator >= 0:
+			c.End = i
+			conflicts = append(conflicts, c)
+			c = Conflict{Start: -1, Base: -1, Separator: -1}
+		}
+	}
+
+	b.conflicts, b.conflictsRevision = conflicts, b.revision
+	return conflicts
+}
+
+// ConflictAt returns the conflict block that contains the given line, if any
+func (b *Buffer) ConflictAt(lineN int) (Conflict, bool) {
+	for _, c := range b.Conflicts() {
+		if lineN >= c.Start && lineN <= c.End {
+			return c, true
+		}
+	}
+	return Conflict{}, false
+}
+
+// conflictStyle returns the style used to highlight the given line if it is
+// part of a conflict block
+func (v *View) conflictStyle(lineN int) (tcell.Style, bool) {
+	c, ok := v.Buf.ConflictAt(lineN)
+	if !ok {
+		return v.colorscheme.defaultStyle(), false
+	}
+
+	group, fallback := "conflict-marker", tcell.ColorGray
+	oursStart, oursEnd := c.Ours()
+	theirsStart, theirsEnd := c.Theirs()
+	switch {
+	case lineN >= oursStart && lineN < oursEnd:
+		group, fallback = "conflict-ours", tcell.ColorDarkGreen
+	case lineN >= theirsStart && lineN < theirsEnd:
+		group, fallback = "conflict-theirs", tcell.ColorNavy
+	case c.Base >= 0 && lineN > c.Base && lineN < c.Separator:
+		group, fallback = "conflict-base", tcell.ColorOlive
+	}
+
+	color := fallback
+	if style, ok := v.colorscheme[group]; ok {
+		color, _, _ = style.Decompose()
+	}
+	return tcell.StyleDefault.Background(color), true
+}
+
+// resolveConflict replaces the conflict block under the cursor with the
+// given ranges of its lines
+func (v *View) resolveConflict(ranges ...[2]int) bool {
+	c, ok := v.Buf.ConflictAt(v.Cursor.Y)
+	if !ok {
+		return false
+	}
+
+	var lines []string
+	for _, r := range ranges {
+		lines = append(lines, v.Buf.Lines(r[0], r[1])...)
+	}
+
+	start := Loc{0, c.Start}
+	end := Loc{Count(v.Buf.Line(c.End)), c.End}
+	text := strings.Join(lines, "\n")
+	if len(lines) == 0 {
+		// Remove the line break after the block as well
+		if c.End+1 < v.Buf.NumLines {
+			end = Loc{0, c.End + 1}
+		} else if c.Start > 0 {
+			start = Loc{Count(v.Buf.Line(c.Start - 1)), c.Start - 1}
+		}
+	}
+
+	v.Cursor.ResetSelection()
+	v.Buf.Replace(start, end, text)
+	v.Cursor.GotoLoc(Loc{0, c.Start})
+	v.Cursor.Relocate()
+	return true
+}
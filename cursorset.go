@@ -0,0 +1,110 @@
+package femto
+
+// A cursorSet holds the cursors of one view of a buffer. Each view that is
+// opened on a buffer has its own set, so views of the same buffer have
+// independent cursors and selections. The buffer's fields for its cursors
+// refer to the set of the view that is currently handling an event or being
+// drawn.
+type cursorSet struct {
+	Cursor    Cursor
+	cursors   []*Cursor // for multiple cursors
+	curCursor int       // the current cursor
+
+	// Whether a view is using this set
+	claimed bool
+}
+
+// newCursorSet returns a cursor set with a single cursor at the given
+// location
+func (b *Buffer) newCursorSet(loc Loc) *cursorSet {
+	s := &cursorSet{}
+	s.Cursor = Cursor{
+		Loc: loc,
+		buf: b,
+	}
+	s.Cursor.LastVisualX = s.Cursor.GetVisualX()
+	s.cursors = []*Cursor{&s.Cursor}
+	return s
+}
+
+// claimCursorSet returns a cursor set for a new view of the buffer. The first
+// view uses the buffer's own cursors, and later views get a new set whose
+// cursor starts at the position of the current cursor.
+func (b *Buffer) claimCursorSet() *cursorSet {
+	if s := b.cursorSets[0]; !s.claimed {
+		s.claimed = true
+		return s
+	}
+
+	s := b.newCursorSet(b.Cursor.Loc)
+	s.claimed = true
+	b.cursorSets = append(b.cursorSets, s)
+	return s
+}
+
+// releaseCursorSet is called when a view stops displaying the buffer. The
+// buffer's own cursors are kept so that they are restored when the buffer is
+// opened again.
+func (b *Buffer) releaseCursorSet(s *cursorSet) {
+	if s == b.cursorSets[0] {
+		s.claimed = false
+		return
+	}
+
+	for i, cs := range b.cursorSets {
+		if cs == s {
+			b.cursorSets = append(b.cursorSets[:i], b.cursorSets[i+1:]...)
+			break
+		}
+	}
+	if b.cursorSet == s {
+		b.cursorSet = b.cursorSets[0]
+	}
+}
+
+// activateCursors makes the view's cursors the buffer's current cursors.
+// Cursors that were moved out of the buffer by an undo in another view are
+// moved back into it.
+func (v *View) activateCursors() {
+	if v.Buf.cursorSet == v.cursors {
+		return
+	}
+	v.Buf.cursorSet = v.cursors
+	for _, c := range v.cursors.cursors {
+		c.Relocate()
+	}
+}
+
+// moveInactiveCursors applies the given function to the location and
+// selection of the cursors of every view but the active one. The active
+// view's cursors are moved by the event handler.
+func (b *Buffer) moveInactiveCursors(move func(Loc) Loc) {
+	for _, s := range b.cursorSets {
+		if s == b.cursorSet {
+			continue
+		}
+		for _, c := range s.cursors {
+			c.Loc = move(c.Loc)
+			c.CurSelection[0] = move(c.CurSelection[0])
+			c.CurSelection[1] = move(c.CurSelection[1])
+			c.OrigSelection[0] = move(c.OrigSelection[0])
+			c.OrigSelection[1] = move(c.OrigSelection[1])
+		}
+	}
+}
+
+// moveInactiveCursorsForInsert moves the cursors of inactive views after
+// text was inserted between start and end
+func (b *Buffer) moveInactiveCursorsForInsert(start, end Loc) {
+	b.moveInactiveCursors(func(loc Loc) Loc {
+		return shiftForInsert(loc, start, end, true)
+	})
+}
+
+// moveInactiveCursorsForRemove moves the cursors of inactive views after the
+// text between start and end was removed
+func (b *Buffer) moveInactiveCursorsForRemove(start, end Loc) {
+	b.moveInactiveCursors(func(loc Loc) Loc {
+		return shiftForRemove(loc, start, end)
+	})
+}
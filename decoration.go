@@ -0,0 +1,181 @@
+package femto
+
+import (
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// DecorationID identifies a decoration that has been added to a buffer
+type DecorationID int
+
+// A Decoration styles a range of text in a buffer independently of syntax
+// highlighting. Decorations are used for things like linter diagnostics or
+// search results.
+type Decoration struct {
+	ID DecorationID
+
+	// The range of text covered by the decoration. End is exclusive.
+	Start Loc
+	End   Loc
+
+	// The style that is merged over the underlying text
+	Style tcell.Style
+
+	// Decorations with a higher priority are merged after (on top of)
+	// decorations with a lower priority
+	Priority int
+}
+
+// insertEnd returns the location just past the given text when it is
+// inserted at pos
+func insertEnd(pos Loc, text []byte) Loc {
+	end := pos
+	for _, r := range string(text) {
+		if r == '\n' {
+			end.X = 0
+			end.Y++
+		} else {
+			end.X++
+		}
+	}
+	return end
+}
+
+// shiftForInsert adjusts a location to account for the insertion of text
+// between start and end. Locations at start are only moved if inclusive is
+// true.
+func shiftForInsert(loc, start, end Loc, inclusive bool) Loc {
+	if loc.LessThan(start) || (loc == start && !inclusive) {
+		return loc
+	}
+	if loc.Y == start.Y {
+		loc.X += end.X - start.X
+	}
+	loc.Y += end.Y - start.Y
+	return loc
+}
+
+// shiftForRemove adjusts a location to account for the removal of the text
+// between start and end. Locations inside the removed range collapse onto
+// start.
+func shiftForRemove(loc, start, end Loc) Loc {
+	if loc.LessEqual(start) {
+		return loc
+	}
+	if loc.LessThan(end) {
+		return start
+	}
+	if loc.Y == end.Y {
+		loc.X += start.X - end.X
+	}
+	loc.Y -= end.Y - start.Y
+	return loc
+}
+
+// AddDecoration adds a decoration that applies the given style to the text
+// between start and end. The decoration follows the text it covers as the
+// buffer is edited.
+func (b *Buffer) AddDecoration(start, end Loc, style tcell.Style, priority int) DecorationID {
+	if end.LessThan(start) {
+		start, end = end, start
+	}
+
+	b.lastDecorationID++
+	b.decorations = append(b.decorations, &Decoration{
+		ID:       b.lastDecorationID,
+		Start:    start,
+		End:      end,
+		Style:    style,
+		Priority: priority,
+	})
+	return b.lastDecorationID
+}
+
+// RemoveDecoration removes the decoration with the given ID
+func (b *Buffer) RemoveDecoration(id DecorationID) {
+	for i, d := range b.decorations {
+		if d.ID == id {
+			b.decorations = append(b.decorations[:i], b.decorations[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearDecorations removes all decorations from the buffer
+func (b *Buffer) ClearDecorations() {
+	b.decorations = nil
+}
+
+// GetDecoration returns the decoration with the given ID, or nil if the
+// decoration no longer exists. A decoration is removed automatically when
+// all of the text it covers is deleted.
+func (b *Buffer) GetDecoration(id DecorationID) *Decoration {
+	for _, d := range b.decorations {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// Decorations returns the decorations that overlap the given range of lines,
+// ordered by ascending priority
+func (b *Buffer) Decorations(startLine, endLine int) []*Decoration {
+	var decorations []*Decoration
+	for _, d := range b.decorations {
+		if d.End.Y >= startLine && d.Start.Y <= endLine {
+			decorations = append(decorations, d)
+		}
+	}
+	sort.SliceStable(decorations, func(i, j int) bool {
+		return decorations[i].Priority < decorations[j].Priority
+	})
+	return decorations
+}
+
+// updateDecorationsForInsert moves the decorations after an insertion
+// between start and end
+func (b *Buffer) updateDecorationsForInsert(start, end Loc) {
+	for _, d := range b.decorations {
+		d.Start = shiftForInsert(d.Start, start, end, true)
+		d.End = shiftForInsert(d.End, start, end, false)
+		if d.End.LessThan(d.Start) {
+			d.End = d.Start
+		}
+	}
+}
+
+// updateDecorationsForRemove moves the decorations after a removal between
+// start and end, and drops any decorations whose text was removed entirely
+func (b *Buffer) updateDecorationsForRemove(start, end Loc) {
+	decorations := b.decorations[:0]
+	for _, d := range b.decorations {
+		empty := d.Start == d.End
+		d.Start = shiftForRemove(d.Start, start, end)
+		d.End = shiftForRemove(d.End, start, end)
+		if empty || d.Start != d.End {
+			decorations = append(decorations, d)
+		}
+	}
+	b.decorations = decorations
+}
+
+// contains returns true if the given location is covered by the decoration
+func (d *Decoration) contains(loc Loc) bool {
+	return loc.GreaterEqual(d.Start) && loc.LessThan(d.End)
+}
+
+// mergeStyle merges the colors and attributes of over on top of base.
+// Default colors in over leave the corresponding colors of base untouched.
+func mergeStyle(base, over tcell.Style) tcell.Style {
+	fg, bg, attr := over.Decompose()
+	_, _, baseAttr := base.Decompose()
+	if fg != tcell.ColorDefault {
+		base = base.Foreground(fg)
+	}
+	if bg != tcell.ColorDefault {
+		base = base.Background(bg)
+	}
+	return base.Attributes(baseAttr | attr)
+}
@@ -0,0 +1,100 @@
+package femto
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDecorationShift(t *testing.T) {
+	// The decoration covers "ef\ngh" of "abc\ndef\nghi"
+	start, end := Loc{1, 1}, Loc{2, 2}
+
+	cases := []struct {
+		name       string
+		edit       func(b *Buffer)
+		start, end Loc
+		removed    bool
+	}{
+		{
+			name:  "insert lines above",
+			edit:  func(b *Buffer) { b.Insert(Loc{0, 0}, "xy\nz") },
+			start: Loc{1, 2},
+			end:   Loc{2, 3},
+		},
+		{
+			name:  "insert newline before start on the same line",
+			edit:  func(b *Buffer) { b.Insert(Loc{0, 1}, "\n") },
+			start: Loc{1, 2},
+			end:   Loc{2, 3},
+		},
+		{
+			name:  "insert lines at start",
+			edit:  func(b *Buffer) { b.Insert(Loc{1, 1}, "x\ny") },
+			start: Loc{1, 2},
+			end:   Loc{2, 3},
+		},
+		{
+			name:  "insert lines inside",
+			edit:  func(b *Buffer) { b.Insert(Loc{2, 1}, "\n") },
+			start: Loc{1, 1},
+			end:   Loc{2, 3},
+		},
+		{
+			name:  "insert lines at end",
+			edit:  func(b *Buffer) { b.Insert(Loc{2, 2}, "x\ny") },
+			start: Loc{1, 1},
+			end:   Loc{2, 2},
+		},
+		{
+			name:  "remove line above",
+			edit:  func(b *Buffer) { b.Remove(Loc{0, 0}, Loc{0, 1}) },
+			start: Loc{1, 0},
+			end:   Loc{2, 1},
+		},
+		{
+			name:  "join with line above",
+			edit:  func(b *Buffer) { b.Remove(Loc{3, 0}, Loc{0, 1}) },
+			start: Loc{4, 0},
+			end:   Loc{2, 1},
+		},
+		{
+			name:  "remove across start",
+			edit:  func(b *Buffer) { b.Remove(Loc{0, 1}, Loc{1, 2}) },
+			start: Loc{0, 1},
+			end:   Loc{1, 1},
+		},
+		{
+			name:  "remove across end",
+			edit:  func(b *Buffer) { b.Remove(Loc{2, 1}, Loc{3, 2}) },
+			start: Loc{1, 1},
+			end:   Loc{2, 1},
+		},
+		{
+			name:    "remove all covered text",
+			edit:    func(b *Buffer) { b.Remove(Loc{0, 1}, Loc{3, 2}) },
+			removed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := NewBufferFromString("abc\ndef\nghi", "")
+			id := b.AddDecoration(start, end, tcell.StyleDefault, 0)
+			c.edit(b)
+
+			d := b.GetDecoration(id)
+			switch {
+			case c.removed && d != nil:
+				t.Fatalf("decoration at %v-%v was not removed", d.Start, d.End)
+			case c.removed:
+				return
+			case d == nil:
+				t.Fatalf("decoration was removed")
+			}
+			if d.Start != c.start || d.End != c.end {
+				t.Errorf("got %v-%v, want %v-%v", d.Start, d.End, c.start, c.end)
+			}
+		})
+	}
+}
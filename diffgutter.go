@@ -0,0 +1,297 @@
+package femto
+
+import (
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffStatus describes how a line of a buffer differs from the buffer's diff base
+type DiffStatus byte
+
+const (
+	// DSUnchanged means the line is the same as in the diff base
+	DSUnchanged DiffStatus = iota
+	// DSAdded means the line does not exist in the diff base
+	DSAdded
+	// DSModified means the line replaces one or more lines of the diff base
+	DSModified
+	// DSDeletedAbove means that lines of the diff base were deleted above the line
+	DSDeletedAbove
+)
+
+// A diffHunk is a contiguous range of lines that differ between the buffer
+// and its diff base. Lines [start, end) of the buffer replace lines
+// [baseStart, baseEnd) of the base.
+type diffHunk struct {
+	start, end         int
+	baseStart, baseEnd int
+
+	// A dirty hunk covers lines that were edited since the differences
+	// were last computed. It is replaced by the actual hunks of its lines
+	// by updateDiff.
+	dirty bool
+}
+
+// SetDiffBase sets the text that the diff gutter compares the buffer against,
+// for example the contents of the file on disk or in version control. A nil
+// base disables the diff.
+func (b *Buffer) SetDiffBase(base []byte) {
+	b.diffBase = strings.Split(strings.Replace(string(base), "\r\n", "\n", -1), "\n")
+	b.hasDiffBase = base != nil
+	b.diffHunks = nil
+	if b.hasDiffBase {
+		// The whole buffer is compared on the next update
+		b.diffHunks = []diffHunk{{0, b.NumLines, 0, len(b.diffBase), true}}
+	}
+}
+
+// DiffBase returns the text that the buffer is compared against, and whether
+// a diff base has been set
+func (b *Buffer) DiffBase() (string, bool) {
+	return strings.Join(b.diffBase, "\n"), b.hasDiffBase
+}
+
+// diffBaseChanged captures the diff base when the diffgutter option is turned
+// on for a buffer that has none. This is the saved text of the buffer: its
+// current text if it is unmodified, or else the file at its path.
+func (b *Buffer) diffBaseChanged() {
+	if b.hasDiffBase || b.binary || b.EventHandler == nil || !b.Settings.Bool("diffgutter") {
+		// Buffers without an event handler are still being created
+		return
+	}
+	if b.Modified() && b.Path != "" {
+		if data, err := ioutil.ReadFile(b.Path); err == nil {
+			b.SetDiffBase(data)
+			return
+		}
+	}
+	b.SetDiffBase([]byte(b.String()))
+}
+
+// diffLines compares two texts line by line and returns the hunks of lines
+// that differ. The base fields of each hunk refer to lines of base, and the
+// others to lines of text.
+func diffLines(base, text string) []diffHunk {
+	differ := dmp.New()
+	baseRunes, textRunes, _ := differ.DiffLinesToRunes(base+"\n", text+"\n")
+	diffs := differ.DiffMainRunes(baseRunes, textRunes, false)
+
+	var hunks []diffHunk
+	var hunk *diffHunk
+	lineN, baseLineN := 0, 0
+	for _, d := range diffs {
+		n := utf8.RuneCountInString(d.Text)
+		if d.Type == dmp.DiffEqual {
+			hunk = nil
+			lineN += n
+			baseLineN += n
+			continue
+		}
+
+		if hunk == nil {
+			hunks = append(hunks, diffHunk{lineN, lineN, baseLineN, baseLineN, false})
+			hunk = &hunks[len(hunks)-1]
+		}
+		if d.Type == dmp.DiffInsert {
+			lineN += n
+			hunk.end = lineN
+		} else {
+			baseLineN += n
+			hunk.baseEnd = baseLineN
+		}
+	}
+	return hunks
+}
+
+// diffLineRange compares two lists of lines and returns the hunks of lines
+// that differ
+func diffLineRange(base, text []string) []diffHunk {
+	switch {
+	case len(base) == 0 && len(text) == 0:
+		return nil
+	case len(base) == 0 || len(text) == 0:
+		return []diffHunk{{0, len(text), 0, len(base), false}}
+	}
+	return diffLines(strings.Join(base, "\n"), strings.Join(text, "\n"))
+}
+
+// updateDiffForEdit marks lines [first, newLast] as needing to be compared
+// with the diff base after they replaced lines [first, oldLast]. The hunks
+// that touch the edited lines are merged into a single dirty hunk, and the
+// hunks after it are moved by the number of added or removed lines.
+func (b *Buffer) updateDiffForEdit(first, oldLast, newLast int) {
+	if !b.hasDiffBase {
+		return
+	}
+
+	oldEnd := oldLast + 1
+	delta := newLast - oldLast
+	start, end := first, oldEnd
+	before, merged := 0, 0
+	var hunks, after []diffHunk
+	for _, h := range b.diffHunks {
+		switch {
+		case h.end < first:
+			hunks = append(hunks, h)
+			before += (h.baseEnd - h.baseStart) - (h.end - h.start)
+		case h.start > oldEnd:
+			h.start += delta
+			h.end += delta
+			after = append(after, h)
+		default:
+			if h.start < start {
+				start = h.start
+			}
+			if h.end > end {
+				end = h.end
+			}
+			merged += (h.baseEnd - h.baseStart) - (h.end - h.start)
+		}
+	}
+
+	dirty := diffHunk{start, end + delta, start + before, end + before + merged, true}
+	b.diffHunks = append(append(hunks, dirty), after...)
+}
+
+// updateDiff compares the lines of the dirty hunks with the diff base, so
+// that only the lines that were edited since the last update are compared
+func (b *Buffer) updateDiff() {
+	if !b.hasDiffBase {
+		return
+	}
+
+	var hunks []diffHunk
+	for _, h := range b.diffHunks {
+		if !h.dirty {
+			hunks = append(hunks, h)
+			continue
+		}
+
+		lines := make([]string, 0, h.end-h.start)
+		for i := h.start; i < h.end; i++ {
+			lines = append(lines, b.Line(i))
+		}
+		for _, d := range diffLineRange(b.diffBase[h.baseStart:h.baseEnd], lines) {
+			d.start += h.start
+			d.end += h.start
+			d.baseStart += h.baseStart
+			d.baseEnd += h.baseStart
+			hunks = append(hunks, d)
+		}
+	}
+	b.diffHunks = hunks
+}
+
+// covers returns true if the hunk is displayed next to the given line. Lines
+// that were deleted at the end of the buffer are shown next to the last line.
+func (h diffHunk) covers(lineN, numLines int) bool {
+	if h.start == h.end {
+		return lineN == h.start || (h.start == numLines && lineN == numLines-1)
+	}
+	return lineN >= h.start && lineN < h.end
+}
+
+// DiffStatus returns how the given line differs from the buffer's diff base
+func (b *Buffer) DiffStatus(lineN int) DiffStatus {
+	h, ok := b.diffHunkAt(lineN)
+	switch {
+	case !ok:
+		return DSUnchanged
+	case h.start == h.end:
+		return DSDeletedAbove
+	case h.baseStart == h.baseEnd:
+		return DSAdded
+	default:
+		return DSModified
+	}
+}
+
+// diffHunkAt returns the hunk that covers the given line, if any
+func (b *Buffer) diffHunkAt(lineN int) (diffHunk, bool) {
+	b.updateDiff()
+	for _, h := range b.diffHunks {
+		if h.covers(lineN, b.NumLines) {
+			return h, true
+		}
+	}
+	return diffHunk{}, false
+}
+
+// RevertDiffHunk replaces the changed lines around the given line with the
+// corresponding lines from the diff base
+func (b *Buffer) RevertDiffHunk(lineN int) bool {
+	h, ok := b.diffHunkAt(lineN)
+	if !ok {
+		return false
+	}
+
+	lines := append([]string(nil), b.diffBase[h.baseStart:h.baseEnd]...)
+	b.replaceLines(h.start, h.end, lines)
+	return true
+}
+
+// replaceLines replaces lines [start, end) of the buffer with the given lines
+func (b *Buffer) replaceLines(start, end int, lines []string) {
+	// Replace whole lines including their newlines. At the end of the buffer
+	// there is no trailing newline so the preceding one is used instead.
+	var from, to Loc
+	var text string
+	switch {
+	case end < b.NumLines:
+		from, to = Loc{0, start}, Loc{0, end}
+		for _, l := range lines {
+			text += l + "\n"
+		}
+	case start > 0:
+		from, to = Loc{Count(b.Line(start - 1)), start - 1}, b.End()
+		for _, l := range lines {
+			text += "\n" + l
+		}
+	default:
+		from, to = b.Start(), b.End()
+		text = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case from == to:
+		b.Insert(from, text)
+	case text == "":
+		b.Remove(from, to)
+	default:
+		b.Replace(from, to, text)
+	}
+}
+
+// diffSigns displays the diff status of each line in the gutter
+type diffSigns struct {
+	view *View
+}
+
+func (d diffSigns) style(group string, fallback tcell.Color) tcell.Style {
+	if style, ok := d.view.colorscheme[group]; ok {
+		return style
+	}
+	return d.view.colorscheme.defaultStyle().Foreground(fallback)
+}
+
+func (d diffSigns) Sign(buf *Buffer, line int) (rune, tcell.Style, bool) {
+	switch buf.DiffStatus(line) {
+	case DSAdded:
+		return '▎', d.style("diff-added", tcell.ColorGreen), true
+	case DSModified:
+		return '▎', d.style("diff-modified", tcell.ColorYellow), true
+	case DSDeletedAbove:
+		return '_', d.style("diff-deleted", tcell.ColorRed), true
+	}
+	return 0, d.view.colorscheme.defaultStyle(), false
+}
+
+func (d diffSigns) GutterClick(v *View, line int, event *tcell.EventMouse) {
+	v.Cursor.ResetSelection()
+	v.Cursor.GotoLoc(Loc{0, line})
+	v.Relocate()
+}
@@ -0,0 +1,404 @@
+package femto
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffView is a tview primitive that displays two buffers side by side and
+// highlights the differences between them. The left buffer is treated as the
+// original text and the right buffer as the changed text. Lines are aligned
+// with filler rows and scrolling is synchronized between the two views.
+type DiffView struct {
+	*tview.Box
+
+	left, right *View
+
+	// The differences between the two buffers. The base fields of each hunk
+	// refer to lines of the left buffer, and the others to lines of the right.
+	hunks []diffHunk
+
+	// The buffer revisions that the hunks were computed from
+	leftRevision, rightRevision int
+
+	// The decorations that highlight the differences in each buffer
+	leftDecorations, rightDecorations []DecorationID
+
+	// The toplines of the views when they were last synchronized
+	leftTopline, rightTopline int
+}
+
+// NewDiffView returns a new diff view that compares the given buffers.
+func NewDiffView(left, right *Buffer) *DiffView {
+	d := &DiffView{
+		Box:           tview.NewBox(),
+		left:          NewView(left),
+		right:         NewView(right),
+		leftRevision:  -1,
+		rightRevision: -1,
+	}
+	d.left.cellview.fillers = make(map[int]int)
+	d.right.cellview.fillers = make(map[int]int)
+	return d
+}
+
+// Left returns the view of the original text.
+func (d *DiffView) Left() *View {
+	return d.left
+}
+
+// Right returns the view of the changed text.
+func (d *DiffView) Right() *View {
+	return d.right
+}
+
+// SetColorscheme sets the colorscheme for both views.
+func (d *DiffView) SetColorscheme(colorscheme Colorscheme) {
+	d.left.SetColorscheme(colorscheme)
+	d.right.SetColorscheme(colorscheme)
+	d.leftRevision, d.rightRevision = -1, -1
+}
+
+// SetRuntimeFiles sets the runtime files for both views.
+func (d *DiffView) SetRuntimeFiles(runtimeFiles *RuntimeFiles) {
+	d.left.SetRuntimeFiles(runtimeFiles)
+	d.right.SetRuntimeFiles(runtimeFiles)
+}
+
+// diffBackground returns a style that sets the background to the foreground
+// color of the given colorscheme group
+func (d *DiffView) diffBackground(group string, fallback tcell.Color) tcell.Style {
+	color := fallback
+	if style, ok := d.left.colorscheme[group]; ok {
+		color, _, _ = style.Decompose()
+	}
+	return tcell.StyleDefault.Background(color)
+}
+
+// update recomputes the differences between the buffers if either of them
+// has changed
+func (d *DiffView) update() {
+	lb, rb := d.left.Buf, d.right.Buf
+	if lb.revision == d.leftRevision && rb.revision == d.rightRevision {
+		return
+	}
+	d.leftRevision, d.rightRevision = lb.revision, rb.revision
+
+	for _, id := range d.leftDecorations {
+		lb.RemoveDecoration(id)
+	}
+	for _, id := range d.rightDecorations {
+		rb.RemoveDecoration(id)
+	}
+	d.leftDecorations, d.rightDecorations = nil, nil
+
+	d.hunks = diffLines(lb.String(), rb.String())
+
+	leftFillers, rightFillers := make(map[int]int), make(map[int]int)
+	for _, h := range d.hunks {
+		leftLen, rightLen := h.baseEnd-h.baseStart, h.end-h.start
+		if leftLen > rightLen {
+			rightFillers[h.end] += leftLen - rightLen
+		} else if rightLen > leftLen {
+			leftFillers[h.baseEnd] += rightLen - leftLen
+		}
+		d.highlightHunk(h)
+	}
+	d.left.cellview.fillers, d.right.cellview.fillers = leftFillers, rightFillers
+}
+
+// decorateLine highlights an entire line of the given side
+func (d *DiffView) decorateLine(left bool, lineN int, style tcell.Style) {
+	b, ids := d.right.Buf, &d.rightDecorations
+	if left {
+		b, ids = d.left.Buf, &d.leftDecorations
+	}
+	if n := Count(b.Line(lineN)); n > 0 {
+		*ids = append(*ids, b.AddDecoration(Loc{0, lineN}, Loc{n, lineN}, style, 0))
+	}
+}
+
+// highlightHunk adds the decorations that highlight a hunk. Lines that were
+// changed rather than inserted or deleted are compared character by
+// character.
+func (d *DiffView) highlightHunk(h diffHunk) {
+	added := d.diffBackground("diff-added", tcell.ColorDarkGreen)
+	deleted := d.diffBackground("diff-deleted", tcell.ColorMaroon)
+	modified := d.diffBackground("diff-modified", tcell.ColorNavy)
+
+	lb, rb := d.left.Buf, d.right.Buf
+	differ := dmp.New()
+	for i := 0; h.baseStart+i < h.baseEnd || h.start+i < h.end; i++ {
+		leftN, rightN := h.baseStart+i, h.start+i
+		switch {
+		case rightN >= h.end:
+			d.decorateLine(true, leftN, deleted)
+		case leftN >= h.baseEnd:
+			d.decorateLine(false, rightN, added)
+		default:
+			d.decorateLine(true, leftN, modified)
+			d.decorateLine(false, rightN, modified)
+
+			diffs := differ.DiffCleanupSemantic(differ.DiffMain(lb.Line(leftN), rb.Line(rightN), false))
+			leftX, rightX := 0, 0
+			for _, diff := range diffs {
+				n := Count(diff.Text)
+				switch diff.Type {
+				case dmp.DiffDelete:
+					id := lb.AddDecoration(Loc{leftX, leftN}, Loc{leftX + n, leftN}, deleted, 1)
+					d.leftDecorations = append(d.leftDecorations, id)
+					leftX += n
+				case dmp.DiffInsert:
+					id := rb.AddDecoration(Loc{rightX, rightN}, Loc{rightX + n, rightN}, added, 1)
+					d.rightDecorations = append(d.rightDecorations, id)
+					rightX += n
+				default:
+					leftX += n
+					rightX += n
+				}
+			}
+		}
+	}
+}
+
+// alignedRow returns the aligned row that is displayed at the top of the
+// given view
+func alignedRow(v *View) int {
+	row := v.Topline + v.cellview.fillerSkip
+	for line, n := range v.cellview.fillers {
+		if line < v.Topline {
+			row += n
+		}
+	}
+	return row
+}
+
+// alignedLine returns the line that should be displayed at the top of a view
+// so that the given aligned row is the first row of the view, along with the
+// number of filler rows above that line that are scrolled out of view.
+func alignedLine(fillers map[int]int, row int) (int, int) {
+	lines := make([]int, 0, len(fillers))
+	for line := range fillers {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	offset := 0
+	for _, line := range lines {
+		n := fillers[line]
+		switch {
+		case row < line+offset:
+			return row - offset, 0
+		case row < line+offset+n:
+			return line, row - (line + offset)
+		}
+		offset += n
+	}
+	return row - offset, 0
+}
+
+// sync scrolls the other view so that its lines are aligned with the given view
+func (d *DiffView) sync(from *View) {
+	to := d.right
+	fromTopline, toTopline := &d.leftTopline, &d.rightTopline
+	if from == d.right {
+		to = d.left
+		fromTopline, toTopline = toTopline, fromTopline
+	}
+
+	// Once the view has been scrolled its top line shows all of its fillers
+	if from.Topline != *fromTopline {
+		from.cellview.fillerSkip = 0
+	}
+
+	line, skip := alignedLine(to.cellview.fillers, alignedRow(from))
+	if line >= to.Buf.NumLines {
+		line, skip = to.Buf.NumLines-1, 0
+	}
+	if line < 0 {
+		line, skip = 0, 0
+	}
+	to.Topline, to.cellview.fillerSkip = line, skip
+	*fromTopline, *toTopline = from.Topline, to.Topline
+}
+
+// focused returns the view that has focus, or the left view if neither does
+func (d *DiffView) focused() *View {
+	if d.right.HasFocus() {
+		return d.right
+	}
+	return d.left
+}
+
+// hunkAt returns the hunk that covers the given line of the given side
+func (d *DiffView) hunkAt(left bool, lineN int) (diffHunk, bool) {
+	d.update()
+	for _, h := range d.hunks {
+		side, numLines := h, d.right.Buf.NumLines
+		if left {
+			side, numLines = diffHunk{h.baseStart, h.baseEnd, h.start, h.end, false}, d.left.Buf.NumLines
+		}
+		if side.covers(lineN, numLines) {
+			return h, true
+		}
+	}
+	return diffHunk{}, false
+}
+
+// copyHunk copies the lines of the hunk under the cursor of the focused view
+// from one side to the other
+func (d *DiffView) copyHunk(toRight bool) bool {
+	v := d.focused()
+	h, ok := d.hunkAt(v == d.left, v.Cursor.Y)
+	if !ok {
+		return false
+	}
+
+	if toRight {
+		if d.right.Readonly {
+			return false
+		}
+		lines := strings.Split(d.left.Buf.String(), "\n")[h.baseStart:h.baseEnd]
+		d.right.Buf.replaceLines(h.start, h.end, lines)
+		d.right.Cursor.Relocate()
+	} else {
+		if d.left.Readonly {
+			return false
+		}
+		lines := strings.Split(d.right.Buf.String(), "\n")[h.start:h.end]
+		d.left.Buf.replaceLines(h.baseStart, h.baseEnd, lines)
+		d.left.Cursor.Relocate()
+	}
+	d.update()
+	return true
+}
+
+// CopyHunkToRight replaces the lines of the hunk under the cursor in the
+// right buffer with the corresponding lines of the left buffer.
+func (d *DiffView) CopyHunkToRight() bool {
+	return d.copyHunk(true)
+}
+
+// CopyHunkToLeft replaces the lines of the hunk under the cursor in the left
+// buffer with the corresponding lines of the right buffer.
+func (d *DiffView) CopyHunkToLeft() bool {
+	return d.copyHunk(false)
+}
+
+// NextHunk moves the cursor of the focused view to the next hunk.
+func (d *DiffView) NextHunk() bool {
+	v := d.focused()
+	d.update()
+	for _, h := range d.hunks {
+		start := h.start
+		if v == d.left {
+			start = h.baseStart
+		}
+		if start > v.Cursor.Y && start < v.Buf.NumLines {
+			v.Cursor.ResetSelection()
+			v.Cursor.GotoLoc(Loc{0, start})
+			v.Relocate()
+			return true
+		}
+	}
+	return false
+}
+
+// PreviousHunk moves the cursor of the focused view to the previous hunk.
+func (d *DiffView) PreviousHunk() bool {
+	v := d.focused()
+	d.update()
+	for i := len(d.hunks) - 1; i >= 0; i-- {
+		start := d.hunks[i].start
+		if v == d.left {
+			start = d.hunks[i].baseStart
+		}
+		if start < v.Cursor.Y {
+			v.Cursor.ResetSelection()
+			v.Cursor.GotoLoc(Loc{0, start})
+			v.Relocate()
+			return true
+		}
+	}
+	return false
+}
+
+// Focus is called when this primitive receives focus.
+func (d *DiffView) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.focused())
+}
+
+// HasFocus returns whether or not this primitive has focus.
+func (d *DiffView) HasFocus() bool {
+	return d.left.HasFocus() || d.right.HasFocus()
+}
+
+// InputHandler returns a handler which passes key events to the focused view.
+func (d *DiffView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		v := d.focused()
+		if handler := v.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+		d.update()
+		d.sync(v)
+	})
+}
+
+// MouseHandler returns a handler which passes mouse events to the view under
+// the mouse.
+func (d *DiffView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return d.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !d.InRect(event.Position()) {
+			return false, nil
+		}
+		for _, v := range []*View{d.left, d.right} {
+			if consumed, capture = v.MouseHandler()(action, event, setFocus); consumed {
+				d.sync(v)
+				return
+			}
+		}
+		return
+	})
+}
+
+// displayFiller draws a filler row that keeps the lines of a diff aligned
+func (v *View) displayFiller(screen tcell.Screen, y int) {
+	style := v.colorscheme.defaultStyle().Dim(true)
+	if st, ok := v.colorscheme["diff-filler"]; ok {
+		style = st
+	}
+	for x := v.x + v.lineNumOffset; x < v.x+v.width; x++ {
+		screen.SetContent(x, y, '╱', nil, style)
+	}
+}
+
+// Draw draws the two views side by side.
+func (d *DiffView) Draw(screen tcell.Screen) {
+	d.Box.Draw(screen)
+	x, y, width, height := d.GetInnerRect()
+
+	d.update()
+	focused := d.focused()
+	d.sync(focused)
+
+	leftWidth := (width - 1) / 2
+	d.left.SetRect(x, y, leftWidth, height)
+	d.right.SetRect(x+leftWidth+1, y, width-leftWidth-1, height)
+	for row := y; row < y+height; row++ {
+		screen.SetContent(x+leftWidth, row, '│', nil, d.left.colorscheme.defaultStyle())
+	}
+
+	// Draw the focused view last so that it owns the terminal cursor
+	if focused == d.left {
+		d.right.Draw(screen)
+		d.left.Draw(screen)
+	} else {
+		d.left.Draw(screen)
+		d.right.Draw(screen)
+	}
+}
@@ -0,0 +1,333 @@
+package femto
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// Editor is a tview primitive that edits several buffers in tabs. A tab bar
+// at the top shows the name of each buffer, and the buffer of the current tab
+// is displayed in a view below it. Each tab remembers its scroll position and
+// cursors, so switching tabs returns to the same place in the buffer.
+type Editor struct {
+	*tview.Box
+
+	view *View
+
+	tabs    []*editorTab
+	current int
+}
+
+// An editorTab is a buffer opened in an editor and the position of the view
+// in it when the tab was last displayed
+type editorTab struct {
+	buf     *Buffer
+	topline int
+	leftCol int
+}
+
+// NewEditor returns a new editor with a tab for each of the given buffers.
+// If no buffers are given, the editor starts with an empty buffer.
+func NewEditor(buffers ...*Buffer) *Editor {
+	if len(buffers) == 0 {
+		buffers = []*Buffer{NewBufferFromString("", "")}
+	}
+
+	e := &Editor{
+		Box:  tview.NewBox(),
+		view: NewView(buffers[0]),
+	}
+	e.view.editor = e
+	for _, b := range buffers {
+		e.tabs = append(e.tabs, &editorTab{buf: b, topline: -1})
+	}
+	e.tabs[0].topline = e.view.Topline
+	return e
+}
+
+// View returns the view that displays the current buffer.
+func (e *Editor) View() *View {
+	return e.view
+}
+
+// Buffers returns the buffers in the order of their tabs.
+func (e *Editor) Buffers() []*Buffer {
+	buffers := make([]*Buffer, len(e.tabs))
+	for i, t := range e.tabs {
+		buffers[i] = t.buf
+	}
+	return buffers
+}
+
+// CurrentBuffer returns the buffer of the current tab.
+func (e *Editor) CurrentBuffer() *Buffer {
+	return e.tabs[e.current].buf
+}
+
+// CurrentTab returns the index of the current tab.
+func (e *Editor) CurrentTab() int {
+	return e.current
+}
+
+// AddBuffer opens a tab for the given buffer after the current tab and
+// switches to it. If the buffer is already open, its tab is made current.
+func (e *Editor) AddBuffer(b *Buffer) {
+	if i := e.TabIndex(b); i >= 0 {
+		e.SwitchTab(i)
+		return
+	}
+
+	i := e.current + 1
+	e.tabs = append(e.tabs, nil)
+	copy(e.tabs[i+1:], e.tabs[i:])
+	e.tabs[i] = &editorTab{buf: b, topline: -1}
+	e.SwitchTab(i)
+}
+
+// SwitchTab makes the tab with the given index current. It returns false if
+// there is no such tab.
+func (e *Editor) SwitchTab(i int) bool {
+	if i < 0 || i >= len(e.tabs) {
+		return false
+	}
+
+	if cur := e.tabs[e.current]; cur.buf == e.view.Buf {
+		cur.topline, cur.leftCol = e.view.Topline, e.view.leftCol
+	}
+
+	e.current = i
+	t := e.tabs[i]
+	if t.buf != e.view.Buf {
+		e.view.OpenBuffer(t.buf)
+		if t.topline >= 0 {
+			// OpenBuffer centers the view on the cursor, which would lose
+			// the position the tab was scrolled to
+			e.view.Topline, e.view.leftCol = t.topline, t.leftCol
+		}
+	}
+	return true
+}
+
+// NextTab switches to the tab after the current one, wrapping around to the
+// first tab.
+func (e *Editor) NextTab() bool {
+	return e.SwitchTab((e.current + 1) % len(e.tabs))
+}
+
+// PreviousTab switches to the tab before the current one, wrapping around to
+// the last tab.
+func (e *Editor) PreviousTab() bool {
+	return e.SwitchTab((e.current + len(e.tabs) - 1) % len(e.tabs))
+}
+
+// CloseTab closes the current tab without saving its buffer and switches to
+// the next tab. The last tab cannot be closed.
+func (e *Editor) CloseTab() bool {
+	return e.CloseTabAt(e.current)
+}
+
+// CloseTabAt closes the tab with the given index without saving its buffer.
+// If it is the current tab, the next tab is made current. It returns false
+// if there is no such tab or it is the last tab.
+func (e *Editor) CloseTabAt(i int) bool {
+	if i < 0 || i >= len(e.tabs) || len(e.tabs) == 1 {
+		return false
+	}
+
+	if i == e.current {
+		next := i + 1
+		if next == len(e.tabs) {
+			next = i - 1
+		}
+		e.SwitchTab(next)
+	}
+
+	e.tabs = append(e.tabs[:i], e.tabs[i+1:]...)
+	if e.current > i {
+		e.current--
+	}
+	return true
+}
+
+// CloseBuffer closes the tab of the given buffer in the same way as
+// CloseTabAt. It returns false if the buffer is not open in the editor or
+// its tab is the last tab.
+func (e *Editor) CloseBuffer(b *Buffer) bool {
+	return e.CloseTabAt(e.TabIndex(b))
+}
+
+// TabIndex returns the index of the tab of the given buffer, or -1 if the
+// buffer is not open in the editor.
+func (e *Editor) TabIndex(b *Buffer) int {
+	for i, t := range e.tabs {
+		if t.buf == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// MoveTab moves the current tab by the given number of positions to the
+// right, or to the left if n is negative.
+func (e *Editor) MoveTab(n int) bool {
+	i := e.current + n
+	if i < 0 || i >= len(e.tabs) || n == 0 {
+		return false
+	}
+
+	t := e.tabs[e.current]
+	if n > 0 {
+		copy(e.tabs[e.current:], e.tabs[e.current+1:i+1])
+	} else {
+		copy(e.tabs[i+1:], e.tabs[i:e.current])
+	}
+	e.tabs[i] = t
+	e.current = i
+	return true
+}
+
+// SetColorscheme sets the colorscheme of the editor's view and tab bar.
+func (e *Editor) SetColorscheme(colorscheme Colorscheme) {
+	e.view.SetColorscheme(colorscheme)
+}
+
+// SetRuntimeFiles sets the runtime files of the editor's view.
+func (e *Editor) SetRuntimeFiles(runtimeFiles *RuntimeFiles) {
+	e.view.SetRuntimeFiles(runtimeFiles)
+}
+
+// Focus is called when this primitive receives focus.
+func (e *Editor) Focus(delegate func(p tview.Primitive)) {
+	delegate(e.view)
+}
+
+// HasFocus returns whether or not this primitive has focus.
+func (e *Editor) HasFocus() bool {
+	return e.view.HasFocus()
+}
+
+// InputHandler returns a handler which passes key events to the view.
+func (e *Editor) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return e.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if handler := e.view.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// MouseHandler returns a handler which switches tabs when a tab is clicked
+// and otherwise passes mouse events to the view.
+func (e *Editor) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return e.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		if !e.InRect(x, y) {
+			return false, nil
+		}
+
+		_, top, _, _ := e.GetInnerRect()
+		if y == top {
+			if action == tview.MouseLeftClick {
+				for i, t := range e.tabLayout() {
+					if x >= t[0] && x < t[1] {
+						e.SwitchTab(i)
+						break
+					}
+				}
+			}
+			setFocus(e.view)
+			return true, nil
+		}
+		return e.view.MouseHandler()(action, event, setFocus)
+	})
+}
+
+// tabTitle returns the text of the tab bar entry for a tab
+func (e *Editor) tabTitle(t *editorTab) string {
+	title := " " + t.buf.GetName()
+	if t.buf.Modified() {
+		title += " +"
+	}
+	return title + " "
+}
+
+// tabLayout returns the screen columns [start, end) of each tab in the tab
+// bar. Tabs are scrolled so that the current tab is visible. Tabs that are
+// scrolled out of view have an empty range.
+func (e *Editor) tabLayout() [][2]int {
+	x, _, width, _ := e.GetInnerRect()
+
+	widths := make([]int, len(e.tabs))
+	total := 0
+	for i, t := range e.tabs {
+		widths[i] = runewidth.StringWidth(e.tabTitle(t))
+		if i <= e.current {
+			total += widths[i]
+		}
+	}
+
+	// Skip tabs at the start until the current tab fits
+	first := 0
+	for total > width && first < e.current {
+		total -= widths[first]
+		first++
+	}
+
+	layout := make([][2]int, len(e.tabs))
+	for i := range e.tabs {
+		if i < first {
+			layout[i] = [2]int{x, x}
+			continue
+		}
+		layout[i] = [2]int{x, x + widths[i]}
+		x += widths[i]
+	}
+	return layout
+}
+
+// displayTabBar draws the tab bar on the given row
+func (e *Editor) displayTabBar(screen tcell.Screen, y int) {
+	x0, _, width, _ := e.GetInnerRect()
+
+	colorscheme := e.view.colorscheme
+	style := e.view.colorscheme.defaultStyle().Reverse(true)
+	if s, ok := colorscheme["tabbar"]; ok {
+		style = s
+	}
+	activeStyle := e.view.colorscheme.defaultStyle()
+	if s, ok := colorscheme["tabbar.active"]; ok {
+		activeStyle = s
+	}
+
+	for x := x0; x < x0+width; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+
+	for i, r := range e.tabLayout() {
+		st := style
+		if i == e.current {
+			st = activeStyle
+		}
+		x := r[0]
+		for _, c := range e.tabTitle(e.tabs[i]) {
+			if x >= r[1] || x >= x0+width {
+				break
+			}
+			screen.SetContent(x, y, c, nil, st)
+			x += runewidth.RuneWidth(c)
+		}
+	}
+}
+
+// Draw draws the tab bar and the view of the current buffer.
+func (e *Editor) Draw(screen tcell.Screen) {
+	e.Box.Draw(screen)
+	x, y, width, height := e.GetInnerRect()
+	if height <= 0 {
+		return
+	}
+
+	e.displayTabBar(screen, y)
+	e.view.SetRect(x, y+1, width, height-1)
+	e.view.Draw(screen)
+}
@@ -0,0 +1,271 @@
+package femto
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// An editorConfigSection is a section of an .editorconfig file whose
+// properties apply to the files matching its glob
+type editorConfigSection struct {
+	pattern    *regexp.Regexp
+	properties map[string]string
+}
+
+// An editorConfig is a parsed .editorconfig file
+type editorConfig struct {
+	root     bool
+	sections []editorConfigSection
+}
+
+// parseEditorConfig parses an .editorconfig file located in the given
+// directory. Property names and values are lowercased, as the format is case
+// insensitive.
+func parseEditorConfig(r io.Reader, dir string) (*editorConfig, error) {
+	config := &editorConfig{}
+	var section *editorConfigSection
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			pattern, err := editorConfigGlob(line[1:len(line)-1], dir)
+			if err != nil {
+				// Skip the properties of sections that cannot match anything
+				section = &editorConfigSection{properties: map[string]string{}}
+				continue
+			}
+			config.sections = append(config.sections, editorConfigSection{pattern, map[string]string{}})
+			section = &config.sections[len(config.sections)-1]
+			continue
+		}
+
+		eq := strings.IndexAny(line, "=:")
+		if eq < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:eq]))
+		value := strings.ToLower(strings.TrimSpace(line[eq+1:]))
+
+		if section == nil {
+			if key == "root" {
+				config.root = value == "true"
+			}
+			continue
+		}
+		section.properties[key] = value
+	}
+	return config, scanner.Err()
+}
+
+// editorConfigRange matches a numeric range such as {1..10} in a glob
+var editorConfigRange = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}`)
+
+// editorConfigGlob converts an .editorconfig section name into a regular
+// expression that matches absolute, slash-separated paths. Globs without a
+// slash match files in any directory below dir.
+func editorConfigGlob(glob, dir string) (*regexp.Regexp, error) {
+	dir = filepath.ToSlash(dir)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if strings.HasPrefix(glob, "/") {
+		glob = glob[1:]
+	} else if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+
+	var re strings.Builder
+	re.WriteString("^" + regexp.QuoteMeta(dir))
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" also matches no directories at all
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end
+		case '{':
+			if m := editorConfigRange.FindStringSubmatch(glob[i:]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				var nums []string
+				for n := lo; n <= hi; n++ {
+					nums = append(nums, strconv.Itoa(n))
+				}
+				re.WriteString("(?:" + strings.Join(nums, "|") + ")")
+				i += len(m[0]) - 1
+				continue
+			}
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 || !strings.Contains(glob[i:i+end], ",") {
+				re.WriteString(`\{`)
+				continue
+			}
+			braces++
+			re.WriteString("(?:")
+		case ',':
+			if braces > 0 {
+				re.WriteString("|")
+			} else {
+				re.WriteString(",")
+			}
+		case '}':
+			if braces > 0 {
+				braces--
+				re.WriteString(")")
+			} else {
+				re.WriteString(`\}`)
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// editorConfigProperties returns the .editorconfig properties that apply to
+// the file at the given path. Files closer to the path take precedence.
+func editorConfigProperties(path string) (map[string]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []*editorConfig
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		f, err := os.Open(filepath.Join(dir, ".editorconfig"))
+		if err == nil {
+			config, err := parseEditorConfig(f, dir)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, config)
+			if config.root {
+				break
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	properties := map[string]string{}
+	slashPath := filepath.ToSlash(path)
+	for i := len(configs) - 1; i >= 0; i-- {
+		for _, section := range configs[i].sections {
+			if !section.pattern.MatchString(slashPath) {
+				continue
+			}
+			for k, v := range section.properties {
+				if v == "unset" {
+					delete(properties, k)
+				} else {
+					properties[k] = v
+				}
+			}
+		}
+	}
+	return properties, nil
+}
+
+// ApplyEditorConfig applies the .editorconfig files in the directories above
+// the buffer's path to its settings. It is called when a buffer with a path
+// is created, and can be called again if the files change.
+func (b *Buffer) ApplyEditorConfig() error {
+	b.Settings.ClearSource(SourceEditorConfig)
+	if b.Path == "" {
+		return nil
+	}
+
+	properties, err := editorConfigProperties(b.Path)
+	if err != nil {
+		return err
+	}
+
+	set := func(name string, value interface{}) {
+		// Invalid values are ignored, as editors are expected to do
+		b.Settings.SetFrom(name, value, SourceEditorConfig)
+	}
+
+	switch properties["indent_style"] {
+	case "space":
+		set("tabstospaces", true)
+	case "tab":
+		set("tabstospaces", false)
+	}
+
+	tabsize := properties["indent_size"]
+	if tabsize == "tab" || properties["indent_style"] == "tab" && properties["tab_width"] != "" {
+		tabsize = properties["tab_width"]
+	}
+	if tabsize == "" {
+		tabsize = properties["tab_width"]
+	}
+	if tabsize != "" {
+		set("tabsize", tabsize)
+	}
+
+	switch properties["end_of_line"] {
+	case "lf":
+		set("fileformat", "unix")
+	case "crlf":
+		set("fileformat", "dos")
+	}
+
+	if v, ok := properties["trim_trailing_whitespace"]; ok {
+		set("rmtrailingws", v)
+	}
+	if v, ok := properties["insert_final_newline"]; ok {
+		set("eofnewline", v)
+	}
+	if v, ok := properties["charset"]; ok {
+		set("encoding", v)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package femto
+
+import "testing"
+
+func TestEditorConfigGlob(t *testing.T) {
+	cases := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*", "/p/a.go", true},
+		{"*", "/p/sub/a.go", true},
+		{"*.go", "/p/sub/a.go", true},
+		{"*.go", "/p/a.go.txt", false},
+		{"*.{js,ts}", "/p/a.ts", true},
+		{"*.{js,ts}", "/p/a.go", false},
+		{"{a,b}/*.go", "/p/b/c.go", true},
+		{"{a,b}/*.go", "/p/x/b/c.go", false},
+		{"{single}.go", "/p/{single}.go", true},
+		{"file{1..3}.txt", "/p/file2.txt", true},
+		{"file{1..3}.txt", "/p/file4.txt", false},
+		{"/src/*.go", "/p/src/a.go", true},
+		{"/src/*.go", "/p/src/sub/a.go", false},
+		{"src/**.go", "/p/src/sub/a.go", true},
+		{"src/**/*.go", "/p/src/a.go", true},
+		{"src/**/*.go", "/p/src/x/y/a.go", true},
+		{"src/**/*.go", "/p/other/a.go", false},
+		{"?.md", "/p/a.md", true},
+		{"?.md", "/p/ab.md", false},
+		{"[abc].txt", "/p/b.txt", true},
+		{"[abc].txt", "/p/d.txt", false},
+		{"[!abc].txt", "/p/d.txt", true},
+		{"[!abc].txt", "/p/a.txt", false},
+		{"[a-c].txt", "/p/c.txt", true},
+		{"[abc.txt", "/p/[abc.txt", true},
+		{`\*.txt`, "/p/*.txt", true},
+		{`\*.txt`, "/p/a.txt", false},
+		{"Makefile", "/q/Makefile", false},
+	}
+
+	for _, c := range cases {
+		re, err := editorConfigGlob(c.glob, "/p")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.glob, err)
+			continue
+		}
+		if got := re.MatchString(c.path); got != c.match {
+			t.Errorf("%s matching %s: got %v, want %v", c.glob, c.path, got, c.match)
+		}
+	}
+}
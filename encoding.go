@@ -0,0 +1,78 @@
+package femto
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// textEncodings maps the values of the encoding option to the character sets
+// they name. UTF-8 text is used as is.
+var textEncodings = map[string]encoding.Encoding{
+	"utf-8-bom": unicode.UTF8BOM,
+	"latin1":    charmap.ISO8859_1,
+	"utf-16be":  unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"utf-16le":  unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+}
+
+// utf16ByteOrders maps the UTF-16 values of the encoding option to their
+// byte order
+var utf16ByteOrders = map[string]unicode.Endianness{
+	"utf-16be": unicode.BigEndian,
+	"utf-16le": unicode.LittleEndian,
+}
+
+// decodeText converts the contents of a file in the character set given by
+// the encoding option to UTF-8. It returns false if the option is utf-8 or
+// the contents cannot be decoded.
+// Binary contents are only decoded as UTF-16, whose NUL bytes look binary,
+// and only if the decoded text does not look binary. The other character
+// sets accept any bytes, so a charset option for all files would otherwise
+// turn binary files into text.
+func (b *Buffer) decodeText(data []byte, binary bool) ([]byte, bool) {
+	name := b.Settings.String("encoding")
+	enc, ok := textEncodings[name]
+	_, utf16 := utf16ByteOrders[name]
+	if !ok || binary && !utf16 {
+		return nil, false
+	}
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, false
+	}
+	if binary {
+		sample := text
+		if len(sample) > binaryCheckSize {
+			sample = sample[:binaryCheckSize]
+		}
+		// Bytes that are not valid UTF-16 are decoded as the replacement
+		// character
+		if isBinary(sample) || bytes.ContainsRune(text, utf8.RuneError) {
+			return nil, false
+		}
+	}
+	b.utf16BOM = utf16 && (bytes.HasPrefix(data, []byte{0xfe, 0xff}) || bytes.HasPrefix(data, []byte{0xff, 0xfe}))
+	return text, true
+}
+
+// encodeText converts UTF-8 text to the character set given by the encoding
+// option. Characters that the character set cannot represent are replaced.
+// UTF-16 text only gets a byte order mark if the file had one.
+func (b *Buffer) encodeText(text []byte) []byte {
+	name := b.Settings.String("encoding")
+	enc, ok := textEncodings[name]
+	if !ok {
+		return text
+	}
+	if order, ok := utf16ByteOrders[name]; ok && !b.utf16BOM {
+		enc = unicode.UTF16(order, unicode.IgnoreBOM)
+	}
+	data, err := encoding.ReplaceUnsupported(enc.NewEncoder()).Bytes(text)
+	if err != nil {
+		return text
+	}
+	return data
+}
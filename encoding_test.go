@@ -0,0 +1,55 @@
+package femto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	cases := []struct {
+		name     string
+		encoding string
+		data     string
+		binary   bool
+		text     string
+		ok       bool
+	}{
+		{name: "utf-8", encoding: "utf-8", data: "héllo", ok: false},
+		{name: "utf-8-bom", encoding: "utf-8-bom", data: "\xef\xbb\xbfhéllo\n", text: "héllo\n", ok: true},
+		{name: "utf-8-bom without bom", encoding: "utf-8-bom", data: "héllo\n", text: "héllo\n", ok: true},
+		{name: "latin1", encoding: "latin1", data: "h\xe9llo\n", text: "héllo\n", ok: true},
+		{name: "utf-16le", encoding: "utf-16le", data: "h\x00\xe9\x00\n\x00", binary: true, text: "hé\n", ok: true},
+		{name: "utf-16be with bom", encoding: "utf-16be", data: "\xfe\xff\x00h\x00\xe9\x00\n", binary: true, text: "hé\n", ok: true},
+		{name: "utf-16 odd length", encoding: "utf-16le", data: "h\x00i", binary: true, ok: false},
+		{name: "utf-16 binary", encoding: "utf-16le", data: "\x01\x00\x02\x00\x03\x00", binary: true, ok: false},
+		{name: "latin1 binary", encoding: "latin1", data: "\x89PNG\r\n\x1a\n\x00", binary: true, ok: false},
+		{name: "utf-8-bom binary", encoding: "utf-8-bom", data: "\x89PNG\r\n\x1a\n\x00", binary: true, ok: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := NewBufferFromString("", "")
+			b.Settings.Set("encoding", c.encoding)
+
+			text, ok := b.decodeText([]byte(c.data), c.binary)
+			if ok != c.ok {
+				t.Fatalf("got ok %v, want %v", ok, c.ok)
+			}
+			if !ok {
+				return
+			}
+			if string(text) != c.text {
+				t.Fatalf("got %q, want %q", text, c.text)
+			}
+
+			// The BOM is written even if the file did not have one
+			data := []byte(c.data)
+			if c.encoding == "utf-8-bom" && !bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+				data = append([]byte("\xef\xbb\xbf"), data...)
+			}
+			if got := b.encodeText(text); !bytes.Equal(got, data) {
+				t.Errorf("encoded as %q, want %q", got, data)
+			}
+		})
+	}
+}
@@ -22,6 +22,9 @@ const (
 // TextEvent holds data for a manipulation on some text that can be undone
 type TextEvent struct {
 	C Cursor
+	// The cursors of the view that made the event. C is only restored on
+	// undo and redo in the same view.
+	cursors *cursorSet
 
 	EventType int
 	Deltas    []Delta
@@ -50,7 +53,7 @@ func ExecuteTextEvent(t *TextEvent, buf *Buffer) {
 			t.Deltas[i].Text = buf.remove(d.Start, d.End)
 			buf.insert(d.Start, []byte(d.Text))
 			t.Deltas[i].Start = d.Start
-			t.Deltas[i].End = Loc{d.Start.X + Count(d.Text), d.Start.Y}
+			t.Deltas[i].End = insertEnd(d.Start, []byte(d.Text))
 		}
 		for i, j := 0, len(t.Deltas)-1; i < j; i, j = i+1, j-1 {
 			t.Deltas[i], t.Deltas[j] = t.Deltas[j], t.Deltas[i]
@@ -104,23 +107,46 @@ func (eh *EventHandler) ApplyDiff(new string) {
 func (eh *EventHandler) Insert(start Loc, text string) {
 	e := &TextEvent{
 		C:         *eh.buf.cursors[eh.buf.curCursor],
+		cursors:   eh.buf.cursorSet,
 		EventType: TextEventInsert,
 		Deltas:    []Delta{{text, start, Loc{0, 0}}},
 		Time:      time.Now(),
 	}
 	eh.Execute(e)
 	e.Deltas[0].End = start.Move(Count(text), eh.buf)
-	end := e.Deltas[0].End
+	eh.moveCursorsForInsert(start, e.Deltas[0].End, text)
+}
 
-	for _, c := range eh.buf.cursors {
-		move := func(loc Loc) Loc {
-			if start.Y != end.Y && loc.GreaterThan(start) {
-				loc.Y += end.Y - start.Y
-			} else if loc.Y == start.Y && loc.GreaterEqual(start) {
-				loc = loc.Move(Count(text), eh.buf)
-			}
-			return loc
+// moveCursorsForInsert moves the cursors after text has been inserted
+// between start and end
+func (eh *EventHandler) moveCursorsForInsert(start, end Loc, text string) {
+	eh.moveCursors(func(loc Loc) Loc {
+		if start.Y != end.Y && loc.GreaterThan(start) {
+			loc.Y += end.Y - start.Y
+		} else if loc.Y == start.Y && loc.GreaterEqual(start) {
+			loc = loc.Move(Count(text), eh.buf)
 		}
+		return loc
+	})
+}
+
+// moveCursorsForRemove moves the cursors after the text between start and
+// end has been removed
+func (eh *EventHandler) moveCursorsForRemove(start, end Loc) {
+	eh.moveCursors(func(loc Loc) Loc {
+		if start.Y != end.Y && loc.GreaterThan(end) {
+			loc.Y -= end.Y - start.Y
+		} else if loc.Y == end.Y && loc.GreaterEqual(end) {
+			loc = loc.Move(-Diff(start, end, eh.buf), eh.buf)
+		}
+		return loc
+	})
+}
+
+// moveCursors applies the given function to the location and selection of
+// every cursor
+func (eh *EventHandler) moveCursors(move func(Loc) Loc) {
+	for _, c := range eh.buf.cursors {
 		c.Loc = move(c.Loc)
 		c.CurSelection[0] = move(c.CurSelection[0])
 		c.CurSelection[1] = move(c.CurSelection[1])
@@ -134,34 +160,20 @@ func (eh *EventHandler) Insert(start Loc, text string) {
 func (eh *EventHandler) Remove(start, end Loc) {
 	e := &TextEvent{
 		C:         *eh.buf.cursors[eh.buf.curCursor],
+		cursors:   eh.buf.cursorSet,
 		EventType: TextEventRemove,
 		Deltas:    []Delta{{"", start, end}},
 		Time:      time.Now(),
 	}
 	eh.Execute(e)
-
-	for _, c := range eh.buf.cursors {
-		move := func(loc Loc) Loc {
-			if start.Y != end.Y && loc.GreaterThan(end) {
-				loc.Y -= end.Y - start.Y
-			} else if loc.Y == end.Y && loc.GreaterEqual(end) {
-				loc = loc.Move(-Diff(start, end, eh.buf), eh.buf)
-			}
-			return loc
-		}
-		c.Loc = move(c.Loc)
-		c.CurSelection[0] = move(c.CurSelection[0])
-		c.CurSelection[1] = move(c.CurSelection[1])
-		c.OrigSelection[0] = move(c.OrigSelection[0])
-		c.OrigSelection[1] = move(c.OrigSelection[1])
-		c.LastVisualX = c.GetVisualX()
-	}
+	eh.moveCursorsForRemove(start, end)
 }
 
 // MultipleReplace creates an multiple insertions executes them
 func (eh *EventHandler) MultipleReplace(deltas []Delta) {
 	e := &TextEvent{
 		C:         *eh.buf.cursors[eh.buf.curCursor],
+		cursors:   eh.buf.cursorSet,
 		EventType: TextEventReplace,
 		Deltas:    deltas,
 		Time:      time.Now(),
@@ -170,9 +182,18 @@ func (eh *EventHandler) MultipleReplace(deltas []Delta) {
 }
 
 // Replace deletes from start to end and replaces it with the given string
+// The replacement is a single event that can be undone in one step
 func (eh *EventHandler) Replace(start, end Loc, replace string) {
-	eh.Remove(start, end)
-	eh.Insert(start, replace)
+	e := &TextEvent{
+		C:         *eh.buf.cursors[eh.buf.curCursor],
+		cursors:   eh.buf.cursorSet,
+		EventType: TextEventReplace,
+		Deltas:    []Delta{{replace, start, end}},
+		Time:      time.Now(),
+	}
+	eh.Execute(e)
+	eh.moveCursorsForRemove(start, end)
+	eh.moveCursorsForInsert(start, e.Deltas[0].End, replace)
 }
 
 // Execute a textevent and add it to the undo stack
@@ -226,7 +247,7 @@ func (eh *EventHandler) UndoOneEvent() {
 
 	// Set the cursor in the right place
 	teCursor := t.C
-	if teCursor.Num >= 0 && teCursor.Num < len(eh.buf.cursors) {
+	if t.cursors == eh.buf.cursorSet && teCursor.Num >= 0 && teCursor.Num < len(eh.buf.cursors) {
 		t.C = *eh.buf.cursors[teCursor.Num]
 		eh.buf.cursors[teCursor.Num].Goto(teCursor)
 	} else {
@@ -273,7 +294,7 @@ func (eh *EventHandler) RedoOneEvent() {
 	UndoTextEvent(t, eh.buf)
 
 	teCursor := t.C
-	if teCursor.Num >= 0 && teCursor.Num < len(eh.buf.cursors) {
+	if t.cursors == eh.buf.cursorSet && teCursor.Num >= 0 && teCursor.Num < len(eh.buf.cursors) {
 		t.C = *eh.buf.cursors[teCursor.Num]
 		eh.buf.cursors[teCursor.Num].Goto(teCursor)
 	} else {
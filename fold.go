@@ -0,0 +1,278 @@
+package femto
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A FoldRange is a range of lines that can be folded. When the range is
+// folded, line Start remains visible and lines (Start, End] are hidden.
+type FoldRange struct {
+	Start, End int
+}
+
+// SetFoldRanges sets the ranges that can be folded when the foldmethod option
+// is manual. The ranges follow the text as the buffer is edited.
+func (b *Buffer) SetFoldRanges(ranges []FoldRange) {
+	b.foldRanges = append([]FoldRange(nil), ranges...)
+}
+
+// foldRangeStartingAt returns the fold range whose first line is lineN, if
+// there is one
+func (b *Buffer) foldRangeStartingAt(lineN int) (FoldRange, bool) {
+	switch b.Settings.String("foldmethod") {
+	case "indent":
+		return b.indentFoldRange(lineN)
+	case "brace":
+		return b.braceFoldRange(lineN)
+	default:
+		best, ok := FoldRange{}, false
+		for _, r := range b.foldRanges {
+			if r.Start == lineN && r.End > r.Start && (!ok || r.End > best.End) {
+				best, ok = r, true
+			}
+		}
+		return best, ok
+	}
+}
+
+// indentFoldRange returns the range of lines after lineN that are indented
+// more deeply than lineN
+func (b *Buffer) indentFoldRange(lineN int) (FoldRange, bool) {
+	line := b.Line(lineN)
+	if IsSpacesOrTabs(line) {
+		return FoldRange{}, false
+	}
+
+	tabsize := b.Settings.Int("tabsize")
+	indent := StringWidth(GetLeadingWhitespace(line), tabsize)
+	end := lineN
+	for i := lineN + 1; i < b.NumLines; i++ {
+		l := b.Line(i)
+		if IsSpacesOrTabs(l) {
+			continue
+		}
+		if StringWidth(GetLeadingWhitespace(l), tabsize) <= indent {
+			break
+		}
+		end = i
+	}
+	return FoldRange{lineN, end}, end > lineN
+}
+
+// braceFoldRange returns the range from lineN to the line containing the
+// brace that matches the first opening brace on lineN that is closed on a
+// later line
+func (b *Buffer) braceFoldRange(lineN int) (FoldRange, bool) {
+	for x, r := range b.LineRunes(lineN) {
+		for _, bp := range bracePairs {
+			if r != bp[0] {
+				continue
+			}
+			if match := b.FindMatchingBrace(bp, Loc{x, lineN}); match.Y > lineN {
+				return FoldRange{lineN, match.Y}, true
+			}
+		}
+	}
+	return FoldRange{}, false
+}
+
+// FoldRangeAt returns the innermost fold range that contains the given line
+func (b *Buffer) FoldRangeAt(lineN int) (FoldRange, bool) {
+	for start := lineN; start >= 0; start-- {
+		if r, ok := b.foldRangeStartingAt(start); ok && r.End >= lineN {
+			return r, true
+		}
+	}
+	return FoldRange{}, false
+}
+
+// CloseFold folds the given range of lines
+func (b *Buffer) CloseFold(r FoldRange) {
+	if r.End <= r.Start {
+		return
+	}
+	for _, f := range b.folds {
+		if f == r {
+			return
+		}
+	}
+	b.folds = append(b.folds, r)
+	sort.Slice(b.folds, func(i, j int) bool { return b.folds[i].Start < b.folds[j].Start })
+}
+
+// OpenFold unfolds the folds that start at the given line, or if there are
+// none, the innermost fold that hides it. It returns false if the line is not
+// folded.
+func (b *Buffer) OpenFold(lineN int) bool {
+	folds := b.folds[:0]
+	opened := false
+	for _, f := range b.folds {
+		if f.Start == lineN {
+			opened = true
+			continue
+		}
+		folds = append(folds, f)
+	}
+	b.folds = folds
+	if opened {
+		return true
+	}
+
+	inner := -1
+	for i, f := range b.folds {
+		if f.Start < lineN && f.End >= lineN && (inner < 0 || f.Start > b.folds[inner].Start) {
+			inner = i
+		}
+	}
+	if inner < 0 {
+		return false
+	}
+	b.folds = append(b.folds[:inner], b.folds[inner+1:]...)
+	return true
+}
+
+// OpenAllFolds unfolds every folded range
+func (b *Buffer) OpenAllFolds() {
+	b.folds = nil
+}
+
+// ClosedFolds returns the ranges that are currently folded
+func (b *Buffer) ClosedFolds() []FoldRange {
+	return b.folds
+}
+
+// foldAt returns the outermost closed fold that hides the given line
+func (b *Buffer) foldAt(lineN int) (FoldRange, bool) {
+	for _, f := range b.folds {
+		if f.Start < lineN && f.End >= lineN {
+			return f, true
+		}
+	}
+	return FoldRange{}, false
+}
+
+// IsLineHidden returns true if the given line is hidden by a fold
+func (b *Buffer) IsLineHidden(lineN int) bool {
+	_, hidden := b.foldAt(lineN)
+	return hidden
+}
+
+// foldedLines returns the number of lines hidden after the given line, or 0
+// if no fold starts at the line
+func (b *Buffer) foldedLines(lineN int) int {
+	if b.IsLineHidden(lineN) {
+		return 0
+	}
+	end := lineN
+	for _, f := range b.folds {
+		if f.Start == lineN && f.End > end {
+			end = f.End
+		}
+	}
+	return end - lineN
+}
+
+// nextVisibleLine returns the first line after lineN that is not hidden by a
+// fold
+func (b *Buffer) nextVisibleLine(lineN int) int {
+	return lineN + b.foldedLines(lineN) + 1
+}
+
+// visibleLine returns the line that is displayed in place of lineN: either
+// lineN itself or the first line of the fold that hides it
+func (b *Buffer) visibleLine(lineN int) int {
+	if f, ok := b.foldAt(lineN); ok {
+		return f.Start
+	}
+	return lineN
+}
+
+// lineAfterRows returns the first line after the given number of visible
+// lines starting from top
+func (b *Buffer) lineAfterRows(top, rows int) int {
+	if len(b.folds) == 0 {
+		return top + rows
+	}
+	lineN := top
+	for i := 0; i < rows && lineN < b.NumLines; i++ {
+		lineN = b.nextVisibleLine(lineN)
+	}
+	return lineN
+}
+
+// shiftFoldLine adjusts a line number to account for an edit that replaced
+// lines start through end with lines start through newEnd
+func shiftFoldLine(lineN, start, end, newEnd int) int {
+	switch {
+	case lineN > end:
+		return lineN + newEnd - end
+	case lineN > newEnd:
+		return newEnd
+	}
+	return lineN
+}
+
+// updateFoldsForEdit moves the folds and fold ranges after an edit that
+// replaced lines start through end with lines start through newEnd
+func (b *Buffer) updateFoldsForEdit(start, end, newEnd int) {
+	if start == end && start == newEnd {
+		return
+	}
+	update := func(ranges []FoldRange) []FoldRange {
+		updated := ranges[:0]
+		for _, r := range ranges {
+			r.Start = shiftFoldLine(r.Start, start, end, newEnd)
+			r.End = shiftFoldLine(r.End, start, end, newEnd)
+			if r.End > r.Start {
+				updated = append(updated, r)
+			}
+		}
+		return updated
+	}
+	b.folds = update(b.folds)
+	b.foldRanges = update(b.foldRanges)
+}
+
+// skipFolds moves the cursor out of any fold that hides its line, to the first
+// line of the fold if moving up (dir < 0) or to the line after it if moving
+// down (dir > 0)
+func (v *View) skipFolds(dir int) {
+	f, ok := v.Buf.foldAt(v.Cursor.Y)
+	if !ok {
+		return
+	}
+	y := f.Start
+	if dir > 0 && f.End+1 < v.Buf.NumLines {
+		y = f.End + 1
+	}
+	v.Cursor.Y = y
+	v.Cursor.X = v.Cursor.GetCharPosInLine(y, v.Cursor.LastVisualX)
+}
+
+// displayFoldMarker draws the placeholder for the hidden lines of a fold after
+// the end of its first line
+func (v *View) displayFoldMarker(screen tcell.Screen, x, y, lineN int) {
+	n := v.Buf.foldedLines(lineN)
+	if n == 0 {
+		return
+	}
+
+	style := whitespaceStyle(v.colorscheme, v.colorscheme.defaultStyle())
+	if s, ok := v.colorscheme["fold"]; ok {
+		style = s
+	}
+	text := " ⋯ " + strconv.Itoa(n) + " lines "
+	if n == 1 {
+		text = " ⋯ 1 line "
+	}
+	for _, r := range text {
+		if x >= v.x+v.width {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x++
+	}
+}
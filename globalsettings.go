@@ -0,0 +1,129 @@
+package femto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// GlobalSettings holds the settings loaded from a micro-compatible
+// settings.json file. Top-level keys set options for every buffer, and
+// sections named "ft:<filetype>" set options for buffers of that filetype:
+//
+//	{
+//	    "tabsize": 4,
+//	    "ft:go": {
+//	        "tabstospaces": false
+//	    }
+//	}
+//
+// Options that femto does not know about are ignored so that an existing
+// micro configuration can be used as is.
+type GlobalSettings struct {
+	Global    map[string]interface{}
+	Filetypes map[string]map[string]interface{}
+}
+
+// The settings applied to every new buffer
+var globalSettings *GlobalSettings
+
+// SetGlobalSettings sets the settings that are applied to buffers created
+// afterwards. Passing nil removes the global settings.
+func SetGlobalSettings(g *GlobalSettings) {
+	globalSettings = g
+}
+
+// LoadGlobalSettings reads and parses the settings file at the given path
+func LoadGlobalSettings(path string) (*GlobalSettings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGlobalSettings(data)
+}
+
+// ParseGlobalSettings parses the contents of a settings file. Values of known
+// options are converted to the option's type and validated.
+func ParseGlobalSettings(data []byte) (*GlobalSettings, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing settings: %v", err)
+	}
+
+	g := &GlobalSettings{
+		Global:    map[string]interface{}{},
+		Filetypes: map[string]map[string]interface{}{},
+	}
+	for k, v := range parsed {
+		if strings.HasPrefix(k, "ft:") {
+			section, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("error parsing settings: %q is not an object", k)
+			}
+			values, err := parseSettingsSection(section)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing settings for %q: %v", k, err)
+			}
+			g.Filetypes[strings.TrimPrefix(k, "ft:")] = values
+			continue
+		}
+
+		values, err := parseSettingsSection(map[string]interface{}{k: v})
+		if err != nil {
+			return nil, fmt.Errorf("error parsing settings: %v", err)
+		}
+		for name, value := range values {
+			g.Global[name] = value
+		}
+	}
+	return g, nil
+}
+
+// parseSettingsSection converts and validates the values of the known options
+// in a section of a settings file
+func parseSettingsSection(section map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for name, value := range section {
+		o, ok := options[name]
+		if !ok {
+			continue
+		}
+
+		value, err := convertOption(o, value)
+		if err == nil && o.Validate != nil {
+			err = o.Validate(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for option %q: %v", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// applyGlobalSettings applies the top-level global settings to the buffer
+func (b *Buffer) applyGlobalSettings() {
+	b.Settings.ClearSource(SourceGlobal)
+	if globalSettings == nil {
+		return
+	}
+	for name, value := range globalSettings.Global {
+		b.Settings.SetFrom(name, value, SourceGlobal)
+	}
+}
+
+// applyFiletypeSettings applies the global settings for the given filetype
+// to the buffer, replacing those for any previous filetype
+func (b *Buffer) applyFiletypeSettings(ft string) {
+	b.Settings.ClearSource(SourceFiletype)
+	if globalSettings == nil {
+		return
+	}
+	for name, value := range globalSettings.Filetypes[ft] {
+		// The filetype cannot change itself
+		if name != "filetype" {
+			b.Settings.SetFrom(name, value, SourceFiletype)
+		}
+	}
+}
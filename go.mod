@@ -10,6 +10,6 @@ require (
 	github.com/sergi/go-diff v1.1.0
 	github.com/zyedidia/micro v1.4.1
 	golang.org/x/sys v0.0.0-20201214210602-f9fddec55a1e // indirect
-	golang.org/x/text v0.3.4 // indirect
+	golang.org/x/text v0.3.4
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
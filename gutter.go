@@ -0,0 +1,108 @@
+package femto
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// A SignProvider supplies the signs that are displayed in a column of the
+// gutter to the left of the line numbers, such as error markers, breakpoints
+// or version control changes. Each provider registered with a view gets its
+// own single-cell column.
+type SignProvider interface {
+	// Sign returns the sign to display next to the given line of the buffer.
+	// If ok is false, the line has no sign.
+	Sign(buf *Buffer, line int) (r rune, style tcell.Style, ok bool)
+
+	// GutterClick is called when the user clicks on the provider's column
+	// next to the given line.
+	GutterClick(v *View, line int, event *tcell.EventMouse)
+}
+
+// AddSignProvider adds a column to the gutter whose signs are supplied by
+// the given provider. Columns are displayed in the order in which they were
+// added.
+func (v *View) AddSignProvider(p SignProvider) {
+	v.signProviders = append(v.signProviders, p)
+}
+
+// RemoveSignProvider removes the column for the given provider from the
+// gutter.
+func (v *View) RemoveSignProvider(p SignProvider) {
+	for i, sp := range v.signProviders {
+		if sp == p {
+			v.signProviders = append(v.signProviders[:i], v.signProviders[i+1:]...)
+			return
+		}
+	}
+}
+
+// SignProviders returns the sign providers registered with this view.
+func (v *View) SignProviders() []SignProvider {
+	return v.signProviders
+}
+
+// gutterProviders returns the providers for each sign column in the gutter,
+// including the built-in diff column if the diffgutter option is on
+func (v *View) gutterProviders() []SignProvider {
+	if _, ok := v.Buf.DiffBase(); ok && v.Buf.Settings.Bool("diffgutter") {
+		return append([]SignProvider{diffSigns{v}}, v.signProviders...)
+	}
+	return v.signProviders
+}
+
+// displayGutter draws the sign columns for a visual line. Only the first
+// visual line of a soft-wrapped line shows signs.
+func (v *View) displayGutter(screen tcell.Screen, providers []SignProvider, screenX, screenY, lineN int, softwrapped bool) int {
+	for _, p := range providers {
+		r, style := ' ', v.colorscheme.defaultStyle()
+		if !softwrapped && lineN < v.Buf.NumLines {
+			if sr, st, ok := p.Sign(v.Buf, lineN); ok {
+				r, style = sr, st
+			}
+		}
+		screen.SetContent(screenX, screenY, r, nil, style)
+		screenX++
+	}
+	return screenX
+}
+
+// lineAtRow returns the buffer line displayed at the given screen row, or -1
+// if the row does not display a line.
+func (v *View) lineAtRow(y int) int {
+	row := y - v.y
+	if row < 0 || row >= len(v.cellview.rowLines) {
+		return -1
+	}
+	return v.cellview.rowLines[row]
+}
+
+// MouseHandler returns a handler which receives mouse events for this view.
+func (v *View) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return v.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		if !v.InRect(x, y) {
+			return false, nil
+		}
+
+		v.activateCursors()
+
+		switch action {
+		case tview.MouseLeftDown:
+			setFocus(v)
+			if x >= v.x+v.width && x < v.x+v.width+v.minimapWidth() && y >= v.y && y < v.y+v.height {
+				v.minimapClick(y)
+			}
+			consumed = true
+		case tview.MouseLeftClick:
+			providers := v.gutterProviders()
+			if col := x - v.x; col >= 0 && col < len(providers) {
+				if line := v.lineAtRow(y); line >= 0 && line < v.Buf.NumLines {
+					providers[col].GutterClick(v, line, event)
+				}
+			}
+			consumed = true
+		}
+		return
+	})
+}
@@ -0,0 +1,358 @@
+package femto
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// binaryCheckSize is the number of bytes at the start of a file that are
+// inspected to decide whether the file is binary
+const binaryCheckSize = 8000
+
+// binaryControlRatio is the fraction of control characters above which a
+// sample is considered binary
+const binaryControlRatio = 0.1
+
+// isBinary returns true if the given sample of a file looks like binary data,
+// i.e. it contains NUL bytes or many control characters other than
+// whitespace. Text in encodings other than UTF-8 is not binary.
+func isBinary(sample []byte) bool {
+	control := 0
+	for _, c := range sample {
+		switch {
+		case c == 0:
+			return true
+		case c == '\t', c == '\n', c == '\r', c == '\f', c == '\v', c == '\b', c == 0x1b:
+			// Whitespace and escape sequences are common in text files
+		case c < 0x20 || c == 0x7f:
+			control++
+		}
+	}
+	return len(sample) > 0 && float64(control)/float64(len(sample)) > binaryControlRatio
+}
+
+// A hexEdit records the overwrite of a single byte in a binary buffer
+type hexEdit struct {
+	offset   int
+	old, new byte
+}
+
+// Binary returns true if the buffer holds binary data. Binary buffers are
+// not split into lines; their contents are edited byte by byte in hex mode.
+func (b *Buffer) Binary() bool {
+	return b.binary
+}
+
+// Bytes returns the contents of the buffer as they should be written to disk.
+// For binary buffers these are the exact bytes that were loaded, with any
+// edits applied. For text buffers, lines are joined using the line endings
+// given by the fileformat option, trailing whitespace is removed if the
+// rmtrailingws option is on, a final newline is added if the eofnewline
+// option is on, and the text is encoded in the character set given by the
+// encoding option. The buffer itself is not changed.
+func (b *Buffer) Bytes() []byte {
+	if b.binary {
+		data := make([]byte, len(b.data))
+		copy(data, b.data)
+		return data
+	}
+
+	eol := "\n"
+	if b.Settings.String("fileformat") == "dos" {
+		eol = "\r\n"
+	}
+	rmtrailingws := b.Settings.Bool("rmtrailingws")
+
+	var text bytes.Buffer
+	for i := 0; i < b.NumLines; i++ {
+		line := b.lines[i].data
+		if rmtrailingws {
+			line = bytes.TrimRight(line, " \t")
+		}
+		text.Write(line)
+		if i < b.NumLines-1 {
+			text.WriteString(eol)
+		}
+	}
+	if b.Settings.Bool("eofnewline") && len(b.lines[b.NumLines-1].data) > 0 {
+		text.WriteString(eol)
+	}
+	return b.encodeText(text.Bytes())
+}
+
+// Size returns the number of bytes in a binary buffer
+func (b *Buffer) Size() int {
+	return len(b.data)
+}
+
+// ByteAt returns the byte at the given offset of a binary buffer
+func (b *Buffer) ByteAt(offset int) byte {
+	return b.data[offset]
+}
+
+// SetByte overwrites the byte at the given offset of a binary buffer. The
+// change can be undone with HexUndo.
+func (b *Buffer) SetByte(offset int, value byte) {
+	if offset < 0 || offset >= len(b.data) || b.data[offset] == value {
+		return
+	}
+	b.hexUndo = append(b.hexUndo, hexEdit{offset, b.data[offset], value})
+	b.hexRedo = nil
+	b.setByte(offset, value)
+}
+
+func (b *Buffer) setByte(offset int, value byte) {
+	b.IsModified = true
+	b.revision++
+	b.data[offset] = value
+}
+
+// HexUndo undoes the last byte edit of a binary buffer and returns its offset,
+// or -1 if there is nothing to undo
+func (b *Buffer) HexUndo() int {
+	if len(b.hexUndo) == 0 {
+		return -1
+	}
+	e := b.hexUndo[len(b.hexUndo)-1]
+	b.hexUndo = b.hexUndo[:len(b.hexUndo)-1]
+	b.hexRedo = append(b.hexRedo, e)
+	b.setByte(e.offset, e.old)
+	return e.offset
+}
+
+// HexRedo redoes the last undone byte edit of a binary buffer and returns its
+// offset, or -1 if there is nothing to redo
+func (b *Buffer) HexRedo() int {
+	if len(b.hexRedo) == 0 {
+		return -1
+	}
+	e := b.hexRedo[len(b.hexRedo)-1]
+	b.hexRedo = b.hexRedo[:len(b.hexRedo)-1]
+	b.hexUndo = append(b.hexUndo, e)
+	b.setByte(e.offset, e.new)
+	return e.offset
+}
+
+// hexBytesPerRow returns the number of bytes displayed on each row in hex
+// mode. This is 16 if the view is wide enough and halved until it fits.
+func (v *View) hexBytesPerRow() int {
+	n := 16
+	for n > 1 && hexRowWidth(n) > v.width {
+		n /= 2
+	}
+	return n
+}
+
+// hexRowWidth returns the width of a row in hex mode that displays n bytes:
+// the offset, the hex column with an extra space every 8 bytes and the ASCII
+// column.
+func hexRowWidth(n int) int {
+	return 10 + 3*n + (n-1)/8 + 1 + n
+}
+
+// hexColumn returns the offset from the start of the hex column at which the
+// i-th byte of a row is displayed
+func hexColumn(i int) int {
+	return 3*i + i/8
+}
+
+// hexRelocate scrolls the view so that the hex cursor is visible
+func (v *View) hexRelocate() {
+	perRow := v.hexBytesPerRow()
+	row := v.hexCursor / perRow
+	if row < v.Topline {
+		v.Topline = row
+	}
+	if v.height > 0 && row >= v.Topline+v.height {
+		v.Topline = row - v.height + 1
+	}
+}
+
+// hexMove moves the hex cursor to the given offset and resets it to the high
+// nibble of the byte
+func (v *View) hexMove(offset int) {
+	if offset >= v.Buf.Size() {
+		offset = v.Buf.Size() - 1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	v.hexCursor = offset
+	v.hexLowNibble = false
+}
+
+// hexBinding returns the actions bound to the key of the given event
+func (v *View) hexBinding(e *tcell.EventKey) ([]func(*View) bool, bool) {
+	for key, actions := range v.bindings {
+		if e.Key() != key.keyCode || e.Modifiers() != key.modifiers {
+			continue
+		}
+		if e.Key() == tcell.KeyRune && e.Rune() != key.r {
+			continue
+		}
+		return actions, true
+	}
+	return nil, false
+}
+
+// runHexBinding runs the actions bound to the key of the given event, except
+// for the ones that edit text, which do not apply to binary buffers. It
+// returns false if the key is not bound.
+func (v *View) runHexBinding(e *tcell.EventKey) bool {
+	actions, ok := v.hexBinding(e)
+	if !ok {
+		return false
+	}
+	for _, action := range actions {
+		if !isEditingAction(action) {
+			action(v)
+		}
+	}
+	return true
+}
+
+// handleHexEvent handles a key event for a view that displays a binary buffer
+func (v *View) handleHexEvent(e *tcell.EventKey) {
+	perRow := v.hexBytesPerRow()
+	rowStart := v.hexCursor - v.hexCursor%perRow
+
+	switch e.Key() {
+	case tcell.KeyUp:
+		if v.hexCursor >= perRow {
+			v.hexMove(v.hexCursor - perRow)
+		}
+	case tcell.KeyDown:
+		if v.hexCursor+perRow < v.Buf.Size() {
+			v.hexMove(v.hexCursor + perRow)
+		}
+	case tcell.KeyLeft:
+		v.hexMove(v.hexCursor - 1)
+	case tcell.KeyRight:
+		v.hexMove(v.hexCursor + 1)
+	case tcell.KeyHome:
+		v.hexMove(rowStart)
+	case tcell.KeyEnd:
+		v.hexMove(rowStart + perRow - 1)
+	case tcell.KeyPgUp:
+		v.hexMove(v.hexCursor - perRow*v.height)
+	case tcell.KeyPgDn:
+		v.hexMove(v.hexCursor + perRow*v.height)
+	case tcell.KeyTab:
+		v.hexASCII = !v.hexASCII
+		v.hexLowNibble = false
+	case tcell.KeyRune:
+		if !v.runHexBinding(e) && !v.Readonly && v.Buf.Size() > 0 {
+			v.hexInsertRune(e.Rune())
+		}
+	default:
+		v.runHexBinding(e)
+	}
+
+	v.hexRelocate()
+}
+
+// hexInsertRune overwrites the byte or nibble under the hex cursor with the
+// given character
+func (v *View) hexInsertRune(r rune) {
+	if v.hexASCII {
+		if r < ' ' || r > '~' {
+			return
+		}
+		v.Buf.SetByte(v.hexCursor, byte(r))
+		v.hexMove(v.hexCursor + 1)
+		return
+	}
+
+	var nibble byte
+	switch {
+	case r >= '0' && r <= '9':
+		nibble = byte(r - '0')
+	case r >= 'a' && r <= 'f':
+		nibble = byte(r-'a') + 10
+	case r >= 'A' && r <= 'F':
+		nibble = byte(r-'A') + 10
+	default:
+		return
+	}
+
+	c := v.Buf.ByteAt(v.hexCursor)
+	if v.hexLowNibble {
+		v.Buf.SetByte(v.hexCursor, c&0xf0|nibble)
+		if v.hexCursor+1 < v.Buf.Size() {
+			v.hexMove(v.hexCursor + 1)
+		}
+	} else {
+		v.Buf.SetByte(v.hexCursor, c&0x0f|nibble<<4)
+		v.hexLowNibble = true
+	}
+}
+
+// displayHex draws a binary buffer as rows of offset, hex and ASCII columns
+func (v *View) displayHex(screen tcell.Screen) {
+	perRow := v.hexBytesPerRow()
+	hexX := v.x + 10
+	asciiX := hexX + hexColumn(perRow-1) + 3
+
+	offsetStyle := v.colorscheme.defaultStyle()
+	if style, ok := v.colorscheme["line-number"]; ok {
+		offsetStyle = style
+	}
+	textStyle := v.colorscheme.defaultStyle()
+	if style, ok := v.colorscheme["default"]; ok {
+		textStyle = style
+	}
+	cursorStyle := v.colorscheme.defaultStyle().Reverse(true)
+	if style, ok := v.colorscheme["selection"]; ok {
+		cursorStyle = style
+	}
+
+	screen.HideCursor()
+	for row := 0; row < v.height; row++ {
+		start := (v.Topline + row) * perRow
+		if start >= v.Buf.Size() && !(start == 0 && row == 0) {
+			break
+		}
+
+		y := v.y + row
+		for i, ch := range fmt.Sprintf("%08x", start) {
+			screen.SetContent(v.x+i, y, ch, nil, offsetStyle)
+		}
+
+		for i := 0; i < perRow && start+i < v.Buf.Size(); i++ {
+			offset := start + i
+			c := v.Buf.ByteAt(offset)
+
+			hexStyle, asciiStyle := textStyle, textStyle
+			if offset == v.hexCursor {
+				if v.hexASCII {
+					hexStyle = cursorStyle
+				} else {
+					asciiStyle = cursorStyle
+				}
+			}
+
+			x := hexX + hexColumn(i)
+			for j, ch := range fmt.Sprintf("%02x", c) {
+				screen.SetContent(x+j, y, ch, nil, hexStyle)
+			}
+
+			r := '.'
+			if c >= ' ' && c <= '~' {
+				r = rune(c)
+			}
+			screen.SetContent(asciiX+i, y, r, nil, asciiStyle)
+
+			if offset == v.hexCursor {
+				if v.hexASCII {
+					screen.ShowCursor(asciiX+i, y)
+				} else if v.hexLowNibble {
+					screen.ShowCursor(x+1, y)
+				} else {
+					screen.ShowCursor(x, y)
+				}
+			}
+		}
+	}
+}
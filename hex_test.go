@@ -0,0 +1,31 @@
+package femto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	cases := []struct {
+		name   string
+		sample string
+		binary bool
+	}{
+		{"empty", "", false},
+		{"text", "package main\n\nfunc main() {}\n", false},
+		{"whitespace", "a\tb\r\nc\fd\ve\bf\n", false},
+		{"escape sequences", "\x1b[1mbold\x1b[0m\n", false},
+		{"utf-8", "héllo wörld ✓\n", false},
+		{"nul byte", "text\x00text", true},
+		{"utf-16", "h\x00i\x00", true},
+		{"few control characters", "\x01" + strings.Repeat("a", 20), false},
+		{"many control characters", "\x01\x02\x03\x04" + strings.Repeat("a", 20), true},
+		{"png header", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", true},
+	}
+
+	for _, c := range cases {
+		if got := isBinary([]byte(c.sample)); got != c.binary {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.binary)
+		}
+	}
+}
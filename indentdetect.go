@@ -0,0 +1,85 @@
+package femto
+
+import "strings"
+
+// indentDetectLines is the maximum number of lines that are inspected to
+// detect the indentation style of a buffer
+const indentDetectLines = 1000
+
+// detectIndent guesses whether the given lines are indented with tabs or
+// spaces, and for spaces, the width of one level of indentation. ok is false
+// if there is not enough indentation to tell.
+func detectIndent(lines []string) (tabs bool, width int, ok bool) {
+	tabLines, spaceLines := 0, 0
+	widths := map[int]int{}
+	prev := 0
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			// Blank lines don't change the indentation level
+			continue
+		}
+		indent := line[:len(line)-len(trimmed)]
+
+		switch {
+		case indent == "":
+			prev = 0
+		case indent[0] == '\t':
+			tabLines++
+			prev = 0
+		case !strings.Contains(indent, "\t"):
+			// Continuation lines of block comments are offset by one
+			if trimmed[0] == '*' {
+				continue
+			}
+			spaceLines++
+			delta := len(indent) - prev
+			if delta < 0 {
+				delta = -delta
+			}
+			if delta > 1 && delta <= 8 {
+				widths[delta]++
+			}
+			prev = len(indent)
+		}
+	}
+
+	if tabLines == 0 && spaceLines == 0 {
+		return false, 0, false
+	}
+	if tabLines >= spaceLines {
+		return true, 0, true
+	}
+
+	for w, n := range widths {
+		if n > widths[width] || n == widths[width] && w < width {
+			width = w
+		}
+	}
+	return false, width, true
+}
+
+// detectIndentSettings sets tabstospaces and tabsize from the indentation of
+// the buffer's contents. Detected settings have the lowest precedence, so they
+// do not override settings that were configured explicitly.
+func (b *Buffer) detectIndentSettings() {
+	b.Settings.Unset("tabstospaces", SourceDetected)
+	b.Settings.Unset("tabsize", SourceDetected)
+	if !b.Settings.Bool("detectindent") || b.binary {
+		return
+	}
+
+	n := b.NumLines
+	if n > indentDetectLines {
+		n = indentDetectLines
+	}
+	tabs, width, ok := detectIndent(b.Lines(0, n))
+	if !ok {
+		return
+	}
+
+	b.Settings.SetFrom("tabstospaces", !tabs, SourceDetected)
+	if width > 0 {
+		b.Settings.SetFrom("tabsize", width, SourceDetected)
+	}
+}
@@ -0,0 +1,108 @@
+package femto
+
+import "github.com/gdamore/tcell/v2"
+
+// An indentGuide is the indent guide at column col of lines [start, end]. A
+// column of -1 means that there is no guide.
+type indentGuide struct {
+	col        int
+	start, end int
+}
+
+// contains returns true if the guide is drawn at the given column of the
+// given line
+func (g indentGuide) contains(col, lineN int) bool {
+	return col == g.col && lineN >= g.start && lineN <= g.end
+}
+
+// guideIndent returns the width of the indentation of a line that gets indent
+// guides. Blank lines continue the guides of the lines around them.
+func (b *Buffer) guideIndent(lineN, tabsize int) int {
+	indent := func(l int) (int, bool) {
+		line := b.Line(l)
+		if IsSpacesOrTabs(line) {
+			return 0, false
+		}
+		return StringWidth(GetLeadingWhitespace(line), tabsize), true
+	}
+
+	if w, ok := indent(lineN); ok {
+		return w
+	}
+
+	prev, next := 0, 0
+	for l := lineN - 1; l >= 0; l-- {
+		if w, ok := indent(l); ok {
+			prev = w
+			break
+		}
+	}
+	for l := lineN + 1; l < b.NumLines; l++ {
+		if w, ok := indent(l); ok {
+			next = w
+			break
+		}
+	}
+	if prev < next {
+		return prev
+	}
+	return next
+}
+
+// activeIndentGuide returns the guide of the innermost block that contains
+// the cursor's line
+func (b *Buffer) activeIndentGuide(tabsize int) indentGuide {
+	y := b.Cursor.Y
+	indent := b.guideIndent(y, tabsize)
+	if indent == 0 {
+		return indentGuide{col: -1}
+	}
+
+	col := (indent - 1) / tabsize * tabsize
+	start, end := y, y
+	for start > 0 && b.guideIndent(start-1, tabsize) > col {
+		start--
+	}
+	for end < b.NumLines-1 && b.guideIndent(end+1, tabsize) > col {
+		end++
+	}
+	return indentGuide{col, start, end}
+}
+
+// indentGuideStyle returns the style of an indent guide drawn over text with
+// the given style. The guide of the cursor's block is emphasized.
+func indentGuideStyle(colorscheme Colorscheme, base tcell.Style, active bool) tcell.Style {
+	if active {
+		if s, ok := colorscheme["indent-guide-active"]; ok {
+			return mergeStyle(base, s)
+		}
+		return base
+	}
+	if s, ok := colorscheme["indent-guide"]; ok {
+		return mergeStyle(base, s)
+	}
+	return whitespaceStyle(colorscheme, base)
+}
+
+// displayIndentGuides draws the indent guides of a row after the end of its
+// text, so that they continue across blank lines
+func (v *View) displayIndentGuides(screen tcell.Screen, row, x, y int) {
+	indent := v.cellview.rowGuides[row]
+	if indent == 0 {
+		return
+	}
+
+	xOffset := v.x + v.lineNumOffset
+	tabsize := v.Buf.Settings.Int("tabsize")
+	guideChar := whitespaceGlyph(v.Buf, "indentguidechar")
+	lineN := v.cellview.rowLines[row]
+	for col := 0; col < indent; col += tabsize {
+		sx := xOffset + col - v.leftCol
+		if sx < x || sx < xOffset || sx >= v.x+v.width {
+			continue
+		}
+		_, _, style, _ := screen.GetContent(sx, y)
+		active := v.cellview.activeGuide.contains(col, lineN)
+		screen.SetContent(sx, y, guideChar, nil, indentGuideStyle(v.colorscheme, style, active))
+	}
+}
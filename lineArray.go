@@ -80,7 +80,7 @@ func NewLineArray(size int64, reader io.Reader) *LineArray {
 			if fileformat == 0 {
 				fileformat = 2
 			}
-		} else if len(data) > 0 {
+		} else if len(data) > 0 && data[len(data)-1] == '\n' {
 			if fileformat == 0 {
 				fileformat = 1
 			}
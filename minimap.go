@@ -0,0 +1,196 @@
+package femto
+
+import (
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/zyedidia/micro/cmd/micro/highlight"
+)
+
+// minimapColumnScale is the number of text columns that are summarized by
+// each column of the minimap
+const minimapColumnScale = 4
+
+// minimapWidth returns the number of columns at the right of the view that
+// are used by the minimap. The minimap is left out if it would take more
+// columns than it leaves for the text.
+func (v *View) minimapWidth() int {
+	if v.Buf == nil || v.Buf.binary || !v.Buf.Settings.Bool("minimap") {
+		return 0
+	}
+	width := v.Buf.Settings.Int("minimapwidth")
+	if _, _, boxWidth, _ := v.Box.GetInnerRect(); boxWidth < 2*width {
+		return 0
+	}
+	return width
+}
+
+// minimapLinesPerHalf returns the number of buffer lines that are summarized
+// by each half of a minimap row. The minimap is scaled so that the whole
+// buffer fits in the view.
+func (v *View) minimapLinesPerHalf() int {
+	halves := 2 * v.height
+	if halves <= 0 {
+		return 1
+	}
+	if n := (v.Buf.NumLines + halves - 1) / halves; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// A minimapKey identifies the buffer contents and settings that the cached
+// minimap colors were computed from
+type minimapKey struct {
+	revision int
+	width    int
+	tabsize  int
+	def      *highlight.Def
+}
+
+// updateMinimapColors computes the colors of the minimap columns of each line
+// of the buffer, unless the buffer has not changed since they were last
+// computed. The colors are cached because all lines are highlighted for them.
+func (v *View) updateMinimapColors(width int) {
+	var def *highlight.Def
+	if v.Buf.Settings.Bool("syntax") {
+		def = v.Buf.syntaxDef
+	}
+	key := minimapKey{v.Buf.revision, width, v.Buf.Settings.Int("tabsize"), def}
+	if v.minimapColors != nil && key == v.minimapKey {
+		return
+	}
+
+	if def != nil {
+		v.Buf.highlighter.HighlightMatches(v.Buf, 0, v.Buf.NumLines)
+	}
+	v.minimapColors = make([][]tcell.Color, v.Buf.NumLines)
+	for lineN := range v.minimapColors {
+		v.minimapColors[lineN] = v.minimapLineColors(lineN, width, key.tabsize, def != nil)
+	}
+	if def != nil {
+		// Matches are only kept for the lines being drawn, like CellView
+		// does, to save memory
+		for lineN := 0; lineN < v.Buf.NumLines; lineN++ {
+			v.Buf.SetMatch(lineN, nil)
+		}
+	}
+	v.minimapKey = key
+}
+
+// minimapLineColors returns the color of the first non-whitespace character
+// in each minimap column of a line, or tcell.ColorDefault for columns that
+// only contain whitespace
+func (v *View) minimapLineColors(lineN, width, tabsize int, syntax bool) []tcell.Color {
+	colors := make([]tcell.Color, width)
+	line := v.Buf.LineRunes(lineN)
+	c := 0
+	for x := 0; x < len(line); x++ {
+		col := c / minimapColumnScale
+		if col >= width {
+			break
+		}
+		if !unicode.IsSpace(line[x]) && colors[col] == tcell.ColorDefault {
+			colors[col] = v.minimapCharColor(lineN, x, syntax)
+		}
+		c += runeWidthAt(line[x], c, tabsize)
+	}
+	return colors
+}
+
+// minimapColor returns the color of the first non-whitespace character in
+// lines [start, end) and the given minimap column. ok is false if there is
+// only whitespace there.
+func (v *View) minimapColor(start, end, col int) (color tcell.Color, ok bool) {
+	for lineN := start; lineN < end && lineN < len(v.minimapColors); lineN++ {
+		if color := v.minimapColors[lineN][col]; color != tcell.ColorDefault {
+			return color, true
+		}
+	}
+	return tcell.ColorDefault, false
+}
+
+// minimapCharColor returns the foreground color of the character at the
+// given position according to its syntax group. The syntax matches of the
+// line must be up to date.
+func (v *View) minimapCharColor(lineN, x int, syntax bool) tcell.Color {
+	style := v.colorscheme.defaultStyle()
+	if syntax {
+		match := v.Buf.Match(lineN)
+		for i := x; i >= 0; i-- {
+			if group, ok := match[i]; ok {
+				style = v.colorscheme.GetColor(group.String())
+				break
+			}
+		}
+	}
+
+	fg, _, _ := style.Decompose()
+	if fg == tcell.ColorDefault {
+		return tcell.ColorSilver
+	}
+	return fg
+}
+
+// displayMinimap draws an overview of the whole buffer to the right of the
+// text area. Each row shows two groups of lines with half-block characters
+// colored like the text, and the rows of the lines in the viewport are
+// highlighted.
+func (v *View) displayMinimap(screen tcell.Screen) {
+	width := v.minimapWidth()
+	if width == 0 {
+		return
+	}
+	v.updateMinimapColors(width)
+
+	_, bg, _ := v.colorscheme.defaultStyle().Decompose()
+	if s, ok := v.colorscheme["minimap"]; ok {
+		_, bg, _ = s.Decompose()
+	}
+	viewportBg := tcell.ColorGray
+	if s, ok := v.colorscheme["minimap-viewport"]; ok {
+		_, viewportBg, _ = s.Decompose()
+	}
+
+	perHalf := v.minimapLinesPerHalf()
+	top, bottom := v.Topline, v.Bottomline()
+	x0 := v.x + v.width
+	for row := 0; row < v.height; row++ {
+		upper := 2 * row * perHalf
+		lower := upper + perHalf
+
+		rowBg := bg
+		if upper < bottom && lower+perHalf > top && upper < v.Buf.NumLines {
+			rowBg = viewportBg
+		}
+
+		for col := 0; col < width; col++ {
+			t, topOk := v.minimapColor(upper, lower, col)
+			b, bottomOk := v.minimapColor(lower, lower+perHalf, col)
+
+			r, style := ' ', v.colorscheme.defaultStyle().Background(rowBg)
+			switch {
+			case topOk && bottomOk:
+				r, style = '▀', style.Foreground(t).Background(b)
+			case topOk:
+				r, style = '▀', style.Foreground(t)
+			case bottomOk:
+				r, style = '▄', style.Foreground(b)
+			}
+			screen.SetContent(x0+col, v.y+row, r, nil, style)
+		}
+	}
+}
+
+// minimapClick moves the cursor to the lines shown at the given row of the
+// minimap and centers the view on them
+func (v *View) minimapClick(y int) {
+	lineN := 2*(y-v.y)*v.minimapLinesPerHalf() + v.minimapLinesPerHalf()/2
+	if lineN >= v.Buf.NumLines {
+		lineN = v.Buf.NumLines - 1
+	}
+
+	v.Cursor.ResetSelection()
+	v.Cursor.GotoLoc(Loc{0, v.Buf.visibleLine(lineN)})
+	v.Center()
+}
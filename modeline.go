@@ -0,0 +1,143 @@
+package femto
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// vim: set ts=8 noet :
+	// vi: ts=8 noet
+	vimModeline = regexp.MustCompile(`(?:^|\s)(?:vi|vim|Vim|ex)(?:[<=>]?\d+)?:\s*(?:se(?:t)?\s+([^:]*):|(.*))`)
+
+	// -*- mode: python; tab-width: 4 -*-
+	// -*- python -*-
+	emacsModeline = regexp.MustCompile(`-\*-\s*(.*?)\s*-\*-`)
+)
+
+// parseVimModeline returns the femto settings given by a vim modeline
+func parseVimModeline(line string) map[string]string {
+	m := vimModeline.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	args := m[1]
+	if m[1] == "" {
+		// The second form may separate options with colons as well
+		args = strings.Replace(m[2], ":", " ", -1)
+	}
+
+	settings := map[string]string{}
+	shiftwidth := ""
+	for _, arg := range strings.Fields(args) {
+		name, value := arg, ""
+		if eq := strings.IndexByte(arg, '='); eq >= 0 {
+			name, value = arg[:eq], arg[eq+1:]
+		}
+		switch name {
+		case "ts", "tabstop":
+			settings["tabsize"] = value
+		case "sw", "shiftwidth":
+			shiftwidth = value
+		case "et", "expandtab":
+			settings["tabstospaces"] = "true"
+		case "noet", "noexpandtab":
+			settings["tabstospaces"] = "false"
+		case "ft", "filetype", "syntax", "syn":
+			settings["filetype"] = value
+		case "ff", "fileformat":
+			settings["fileformat"] = value
+		}
+	}
+
+	// When indenting with spaces, the shift width is the indentation width
+	if shiftwidth != "" && shiftwidth != "0" && settings["tabstospaces"] == "true" {
+		settings["tabsize"] = shiftwidth
+	}
+	return settings
+}
+
+// parseEmacsModeline returns the femto settings given by an emacs modeline
+func parseEmacsModeline(line string) map[string]string {
+	m := emacsModeline.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	settings := map[string]string{}
+	if !strings.Contains(m[1], ":") {
+		// -*- mode -*-
+		settings["filetype"] = strings.ToLower(m[1])
+		return settings
+	}
+
+	for _, v := range strings.Split(m[1], ";") {
+		colon := strings.IndexByte(v, ':')
+		if colon < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(v[:colon]))
+		value := strings.TrimSpace(v[colon+1:])
+		switch name {
+		case "mode":
+			settings["filetype"] = strings.ToLower(value)
+		case "tab-width":
+			settings["tabsize"] = value
+		case "indent-tabs-mode":
+			if value == "nil" {
+				settings["tabstospaces"] = "true"
+			} else {
+				settings["tabstospaces"] = "false"
+			}
+		case "coding":
+			if strings.HasSuffix(value, "-dos") {
+				settings["fileformat"] = "dos"
+			} else if strings.HasSuffix(value, "-unix") {
+				settings["fileformat"] = "unix"
+			}
+		}
+	}
+	return settings
+}
+
+// ApplyModelines scans the first and last lines of the buffer for vim and
+// emacs modelines and applies the options they set. This is only done if the
+// modelines option is on, and only for the options listed in modelineoptions.
+func (b *Buffer) ApplyModelines() {
+	b.Settings.ClearSource(SourceModeline)
+	if !b.Settings.Bool("modelines") || b.binary {
+		return
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(b.Settings.String("modelineoptions"), ",") {
+		allowed[strings.TrimSpace(name)] = true
+	}
+
+	n := b.Settings.Int("modelinelines")
+	lines := make([]int, 0, 2*n)
+	for i := 0; i < n && i < b.NumLines; i++ {
+		lines = append(lines, i)
+	}
+	for i := b.NumLines - n; i < b.NumLines; i++ {
+		if i >= n {
+			lines = append(lines, i)
+		}
+	}
+
+	for _, lineN := range lines {
+		line := b.Line(lineN)
+		settings := parseVimModeline(line)
+		if settings == nil {
+			settings = parseEmacsModeline(line)
+		}
+		for name, value := range settings {
+			if allowed[name] {
+				// Modelines come from untrusted files, so invalid values are
+				// ignored
+				b.Settings.SetFrom(name, value, SourceModeline)
+			}
+		}
+	}
+}
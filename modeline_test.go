@@ -0,0 +1,54 @@
+package femto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVimModeline(t *testing.T) {
+	cases := []struct {
+		line string
+		want map[string]string
+	}{
+		{"// vim: set ts=8 noet :", map[string]string{"tabsize": "8", "tabstospaces": "false"}},
+		{"# vim: ts=2:sw=2:et", map[string]string{"tabsize": "2", "tabstospaces": "true"}},
+		{"/* vi: set tabstop=4 shiftwidth=2 expandtab: */", map[string]string{"tabsize": "2", "tabstospaces": "true"}},
+		{"# vim: sw=2 noet", map[string]string{"tabstospaces": "false"}},
+		{"# vim600: ft=python ff=dos", map[string]string{"filetype": "python", "fileformat": "dos"}},
+		{"// ex: set syntax=go:", map[string]string{"filetype": "go"}},
+		{"# vim: set foldmethod=marker :", map[string]string{}},
+		{"novim: ts=2", nil},
+		{"no modeline here", nil},
+	}
+
+	for _, c := range cases {
+		if got := parseVimModeline(c.line); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.line, got, c.want)
+		}
+	}
+}
+
+func TestParseEmacsModeline(t *testing.T) {
+	cases := []struct {
+		line string
+		want map[string]string
+	}{
+		{"# -*- python -*-", map[string]string{"filetype": "python"}},
+		{"# -*- Python -*-", map[string]string{"filetype": "python"}},
+		{
+			"/* -*- mode: C; tab-width: 8; indent-tabs-mode: nil -*- */",
+			map[string]string{"filetype": "c", "tabsize": "8", "tabstospaces": "true"},
+		},
+		{"-*- indent-tabs-mode: t -*-", map[string]string{"tabstospaces": "false"}},
+		{"-*- coding: utf-8-dos -*-", map[string]string{"fileformat": "dos"}},
+		{"-*- coding: latin-1-unix; fill-column: 80 -*-", map[string]string{"fileformat": "unix"}},
+		{"-*- coding: utf-8 -*-", map[string]string{}},
+		{"no modeline here", nil},
+	}
+
+	for _, c := range cases {
+		if got := parseEmacsModeline(c.line); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.line, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package femto
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/zyedidia/micro/cmd/micro/highlight"
+)
+
+// defaultBracketColors are the colors of the nesting levels of brackets when
+// the colorscheme has no bracket-N groups
+var defaultBracketColors = []tcell.Color{tcell.ColorGold, tcell.ColorOrchid, tcell.ColorDeepSkyBlue}
+
+// bracketStyles returns the styles of the nesting levels of brackets, given
+// by the colorscheme groups bracket-1 through bracket-N
+func bracketStyles(colorscheme Colorscheme) []tcell.Style {
+	var styles []tcell.Style
+	for i := 1; ; i++ {
+		style, ok := colorscheme["bracket-"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		styles = append(styles, style)
+	}
+	if len(styles) == 0 {
+		for _, c := range defaultBracketColors {
+			styles = append(styles, colorscheme.defaultStyle().Foreground(c))
+		}
+	}
+	return styles
+}
+
+// isStringOrComment returns true if brackets in text of the given syntax
+// group are not part of the code's structure
+func isStringOrComment(group string) bool {
+	return strings.HasPrefix(group, "constant.string") || strings.HasPrefix(group, "comment")
+}
+
+// scanBrackets finds the brackets of a line outside of strings and comments,
+// starting at the given nesting depth. It returns the depth at the end of the
+// line and, if levels is not nil, stores the nesting level of each bracket in
+// it. The syntax matches of the line must be up to date.
+func (b *Buffer) scanBrackets(lineN, depth int, levels map[int]int) int {
+	var match highlight.LineMatch
+	if b.Settings.Bool("syntax") && b.syntaxDef != nil {
+		match = b.Match(lineN)
+	}
+
+	group := ""
+	for x, r := range b.LineRunes(lineN) {
+		if g, ok := match[x]; ok {
+			group = g.String()
+		}
+		if isStringOrComment(group) {
+			continue
+		}
+
+		switch r {
+		case '(', '[', '{':
+			if levels != nil {
+				levels[x] = depth
+			}
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+			if levels != nil {
+				levels[x] = depth
+			}
+		}
+	}
+	return depth
+}
+
+// bracketDepthAt returns the bracket nesting depth at the start of the given
+// line. The depths are cached until the lines before them are edited.
+func (b *Buffer) bracketDepthAt(lineN int) int {
+	var def *highlight.Def
+	syntax := b.Settings.Bool("syntax") && b.syntaxDef != nil
+	if syntax {
+		def = b.syntaxDef
+	}
+	if def != b.bracketDepthsDef || len(b.bracketDepths) == 0 {
+		// Strings and comments depend on the syntax definition
+		b.bracketDepths = []int{0}
+		b.bracketDepthsDef = def
+	}
+
+	n := len(b.bracketDepths) - 1
+	start := n
+	if syntax && n < lineN {
+		b.highlighter.HighlightMatches(b, n, lineN)
+	}
+	for ; n < lineN && n < b.NumLines; n++ {
+		b.bracketDepths = append(b.bracketDepths, b.scanBrackets(n, b.bracketDepths[n], nil))
+	}
+	if syntax {
+		// The matches of the scanned lines are not needed any more. The
+		// lines before lineN have already been drawn if they are visible.
+		for i := start; i < n; i++ {
+			b.SetMatch(i, nil)
+		}
+	}
+	return b.bracketDepths[n]
+}
+
+// invalidateBracketDepths discards the cached bracket depths of the lines
+// after an edit on the given line
+func (b *Buffer) invalidateBracketDepths(lineN int) {
+	if len(b.bracketDepths) > lineN+1 {
+		b.bracketDepths = b.bracketDepths[:lineN+1]
+	}
+}
@@ -0,0 +1,57 @@
+package femto
+
+import (
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// rulerWidth returns the number of columns used by the line numbers,
+// including the padding after them
+func (v *View) rulerWidth() int {
+	width := len(strconv.Itoa(v.Buf.NumLines))
+	if w := v.Buf.Settings.Int("rulerwidth"); w > width {
+		width = w
+	}
+	return width + v.Buf.Settings.Int("rulerpadding")
+}
+
+// lineNumber returns the text of the ruler for the given line. In relative
+// mode lines are numbered by their distance from the cursor; hybrid mode shows
+// the absolute number of the cursor's line.
+func (v *View) lineNumber(lineN int) string {
+	mode := v.Buf.Settings.String("relativeruler")
+	if mode == "off" || lineN == v.Cursor.Y && mode == "hybrid" {
+		return strconv.Itoa(lineN + 1)
+	}
+
+	distance := lineN - v.Cursor.Y
+	if distance < 0 {
+		distance = -distance
+	}
+	return strconv.Itoa(distance)
+}
+
+// lineNumberStyle returns the style of the ruler for the given line. The
+// linenumberstyle and currentlinenumberstyle options override the
+// colorscheme.
+func (v *View) lineNumberStyle(lineN int) tcell.Style {
+	style := v.colorscheme.defaultStyle()
+	if s, ok := v.colorscheme["line-number"]; ok {
+		style = s
+	}
+	if s := v.Buf.Settings.String("linenumberstyle"); s != "" {
+		style = StringToStyle(s)
+	}
+
+	if lineN != v.Cursor.Y || v.Cursor.HasSelection() {
+		return style
+	}
+	if s, ok := v.colorscheme["current-line-number"]; ok {
+		style = s
+	}
+	if s := v.Buf.Settings.String("currentlinenumberstyle"); s != "" {
+		style = StringToStyle(s)
+	}
+	return style
+}
@@ -11,7 +11,7 @@ type ScrollBar struct {
 
 // Display shows the scrollbar
 func (sb *ScrollBar) Display(screen tcell.Screen) {
-	style := defStyle.Reverse(true)
+	style := sb.view.colorscheme.defaultStyle().Reverse(true)
 	screen.SetContent(sb.view.x+sb.view.width-1, sb.view.y+sb.pos(), ' ', nil, style)
 }
 
@@ -1,40 +1,380 @@
 package femto
 
-// DefaultLocalSettings returns the default local settings
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// An Option describes a buffer setting. The type of the option is the type of
+// its default value, which must be a bool, an int or a string.
+type Option struct {
+	Name        string
+	Default     interface{}
+	Description string
+
+	// Validate, if not nil, checks a value of the correct type before it is
+	// stored
+	Validate func(value interface{}) error
+}
+
+// A SettingsListener is called after the value of an option has changed
+type SettingsListener func(name string, old, new interface{})
+
+// A SettingSource identifies where the value of an option came from. When
+// an option is set by several sources, the value from the source with the
+// highest precedence wins. Sources are listed in increasing precedence.
+type SettingSource int
+
+const (
+	// SourceDefault is the default value of the option
+	SourceDefault SettingSource = iota
+	// SourceDetected is a value detected from the contents of the buffer
+	SourceDetected
+	// SourceGlobal is a value from the global section of the settings file
+	SourceGlobal
+	// SourceFiletype is a value from the section of the settings file for
+	// the buffer's filetype
+	SourceFiletype
+	// SourceFile is a property of the file that was found when it was loaded,
+	// such as its line endings or its size. It outranks the settings file, whose values
+	// are meant for new files.
+	SourceFile
+	// SourceEditorConfig is a value from an .editorconfig file
+	SourceEditorConfig
+	// SourceModeline is a value from a modeline in the buffer
+	SourceModeline
+	// SourceUser is a value set explicitly by the user or the application
+	SourceUser
+
+	numSettingSources
+)
+
+// Settings holds the values of a buffer's options. Each source has its own
+// layer of values, and the effective value of an option is the one from the
+// layer with the highest precedence.
+type Settings struct {
+	layers    [numSettingSources]map[string]interface{}
+	listeners []SettingsListener
+}
+
+var options = map[string]*Option{}
+
+func init() {
+	for _, o := range defaultOptions {
+		if err := RegisterOption(o); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// validateMin returns a validator that checks that an int option is at
+// least min
+func validateMin(min int) func(value interface{}) error {
+	return func(value interface{}) error {
+		if value.(int) < min {
+			return fmt.Errorf("must be at least %d", min)
+		}
+		return nil
+	}
+}
+
+// validateOneOf returns a validator that checks that a string option is one
+// of the given choices
+func validateOneOf(choices ...string) func(value interface{}) error {
+	return func(value interface{}) error {
+		for _, c := range choices {
+			if value.(string) == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %q", choices)
+	}
+}
+
 // Note that filetype is a local only option
-func DefaultLocalSettings() map[string]interface{} {
-	return map[string]interface{}{
-		"autoindent":     true,
-		"autosave":       false,
-		"basename":       false,
-		"colorcolumn":    float64(0),
-		"cursorline":     true,
-		"eofnewline":     false,
-		"fastdirty":      true,
-		"fileformat":     "unix",
-		"filetype":       "Unknown",
-		"hidehelp":       false,
-		"ignorecase":     false,
-		"indentchar":     " ",
-		"keepautoindent": false,
-		"matchbrace":     false,
-		"matchbraceleft": false,
-		"rmtrailingws":   false,
-		"ruler":          true,
-		"savecursor":     false,
-		"saveundo":       false,
-		"scrollbar":      false,
-		"scrollmargin":   float64(3),
-		"scrollspeed":    float64(2),
-		"softwrap":       false,
-		"smartpaste":     true,
-		"splitbottom":    true,
-		"splitright":     true,
-		"statusline":     true,
-		"syntax":         true,
-		"tabmovement":    false,
-		"tabsize":        float64(4),
-		"tabstospaces":   false,
-		"useprimary":     true,
+var defaultOptions = []Option{
+	{"autoindent", true, "Keep the indentation of the previous line when inserting a newline", nil},
+	{"autosave", false, "Save the buffer automatically", nil},
+	{"basename", false, "Show only the base name of the file on the status line", nil},
+	{"colordepth", "auto", "The number of colors the colorscheme is drawn with: auto to use what the terminal supports, 8, 16, 256 or truecolor", validateOneOf("auto", "8", "16", "256", "truecolor")},
+	{"colorcolumn", 0, "Highlight the given column; 0 disables the highlight", validateMin(0)},
+	{"cursorline", true, "Highlight the line the cursor is on", nil},
+	{"currentlinenumberstyle", "", "The style of the cursor's line number, overriding the current-line-number colorscheme group", nil},
+	{"detectindent", true, "Detect tabstospaces and tabsize from the indentation of the file", nil},
+	{"diffgutter", false, "Show how each line differs from the diff base in the gutter", nil},
+	{"encoding", "utf-8", "The character set of the file: utf-8, utf-8-bom, latin1, utf-16be or utf-16le", validateOneOf("utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le")},
+	{"eolchar", "¬", "The character used to mark the end of lines when showeol is on", nil},
+	{"eofnewline", false, "Ensure the file ends with a newline when saving", nil},
+	{"fastdirty", true, "Track modifications with a flag instead of comparing hashes of the text", nil},
+	{"fileformat", "unix", "The line endings used when saving: unix or dos", validateOneOf("unix", "dos")},
+	{"filetype", "Unknown", "The filetype used for syntax highlighting", nil},
+	{"foldmethod", "indent", "How fold ranges are computed: indent, brace, or manual to use the ranges set by the application", validateOneOf("indent", "brace", "manual")},
+	{"hidehelp", false, "Hide the help text on the status line", nil},
+	{"ignorecase", false, "Ignore case when searching", nil},
+	{"indentchar", " ", "The character used to display tabs", nil},
+	{"indentguidechar", "│", "The character used to draw indent guides", nil},
+	{"indentguides", false, "Draw guides at each level of indentation", nil},
+	{"keepautoindent", false, "Keep the indentation of lines that only contain whitespace", nil},
+	{"linenumberstyle", "", "The style of the line numbers, overriding the line-number colorscheme group", nil},
+	{"matchbrace", false, "Highlight the brace that matches the one under the cursor", nil},
+	{"matchbraceleft", false, "Also match the brace to the left of the cursor", nil},
+	{"minimap", false, "Show an overview of the whole buffer at the right of the view", nil},
+	{"minimapwidth", 12, "The width of the minimap", validateMin(1)},
+	{"modelineoptions", "tabsize,tabstospaces,filetype,fileformat", "The comma-separated options that modelines are allowed to set", nil},
+	{"modelinelines", 5, "The number of lines at the start and end of the buffer that are scanned for modelines", validateMin(0)},
+	{"modelines", false, "Apply the options set by vim and emacs modelines in the buffer", nil},
+	{"nbspchar", "⍽", "The character used to display non-breaking spaces when shownbsp is on", nil},
+	{"rainbowbrackets", false, "Color brackets by their nesting depth with the bracket-N colorscheme groups", nil},
+	{"rmtrailingws", false, "Remove trailing whitespace when saving", nil},
+	{"relativeruler", "off", "Number lines relative to the cursor: off, relative, or hybrid to show the absolute number of the cursor's line", validateOneOf("off", "relative", "hybrid")},
+	{"ruler", true, "Show line numbers", nil},
+	{"rulerpadding", 1, "The number of spaces after the line numbers", validateMin(0)},
+	{"rulerwidth", 0, "The minimum width of the line numbers", validateMin(0)},
+	{"savecursor", false, "Remember the cursor position when the file is closed", nil},
+	{"saveundo", false, "Remember the undo history when the file is closed", nil},
+	{"scrollbar", false, "Show a scrollbar", nil},
+	{"scrollmargin", 3, "The number of lines to keep visible above and below the cursor", validateMin(0)},
+	{"scrollspeed", 2, "The number of lines to scroll for each mouse wheel event", validateMin(0)},
+	{"softwrap", false, "Wrap lines that are wider than the view", nil},
+	{"showeol", false, "Mark the end of each line", nil},
+	{"shownbsp", false, "Display non-breaking spaces with nbspchar", nil},
+	{"showtrailingws", false, "Display trailing spaces with trailingwschar and highlight trailing tabs", nil},
+	{"smartpaste", true, "Adjust the indentation of pasted text", nil},
+	{"splitbottom", true, "Open horizontal splits below the current view", nil},
+	{"splitright", true, "Open vertical splits to the right of the current view", nil},
+	{"statusformatl", "$(filename) $(modified)($(line),$(col)) $(mode) | $(opt:filetype) | $(opt:fileformat)", "The format of the left side of the status line", nil},
+	{"statusformatr", "$(help)", "The format of the right side of the status line", nil},
+	{"statusline", false, "Show the status line", nil},
+	{"syntax", true, "Enable syntax highlighting", nil},
+	{"tabmovement", false, "Move over tabs of spaces as if they were tab characters", nil},
+	{"tabsize", 4, "The width of a tab", validateMin(1)},
+	{"tabstospaces", false, "Insert spaces instead of tabs", nil},
+	{"trailingwschar", "·", "The character used to display trailing spaces when showtrailingws is on", nil},
+	{"useprimary", true, "Use the primary selection on X11", nil},
+	{"wordhighlight", false, "Highlight the other occurrences of the word under the cursor", nil},
+	{"wordhighlightdelay", 500, "The number of milliseconds the cursor must rest on a word before it is highlighted", validateMin(0)},
+	{"wordwrap", false, "Break soft-wrapped lines after whitespace instead of in the middle of words", nil},
+	{"wrapatcolorcolumn", false, "Soft wrap lines at the colorcolumn instead of the view width", nil},
+	{"wrapindent", false, "Indent the continuation rows of soft-wrapped lines like the first row", nil},
+	{"wrapmarker", "", "The text displayed at the start of the continuation rows of soft-wrapped lines", nil},
+}
+
+// RegisterOption adds an option to the set of options that buffers support.
+// Buffers that are created afterwards will have the option set to its
+// default value.
+func RegisterOption(o Option) error {
+	if _, ok := options[o.Name]; ok {
+		return fmt.Errorf("option %q is already registered", o.Name)
+	}
+	switch o.Default.(type) {
+	case bool, int, string:
+	default:
+		return fmt.Errorf("option %q has unsupported type %T", o.Name, o.Default)
+	}
+	if o.Validate != nil {
+		if err := o.Validate(o.Default); err != nil {
+			return fmt.Errorf("invalid default for option %q: %v", o.Name, err)
+		}
+	}
+	options[o.Name] = &o
+	return nil
+}
+
+// GetOption returns the registered option with the given name
+func GetOption(name string) (Option, bool) {
+	o, ok := options[name]
+	if !ok {
+		return Option{}, false
+	}
+	return *o, true
+}
+
+// Options returns all registered options sorted by name
+func Options() []Option {
+	all := make([]Option, 0, len(options))
+	for _, o := range options {
+		all = append(all, *o)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// DefaultLocalSettings returns settings with every option set to its default
+func DefaultLocalSettings() *Settings {
+	s := &Settings{}
+	for i := range s.layers {
+		s.layers[i] = map[string]interface{}{}
+	}
+	return s
+}
+
+// convertOption converts a value to the type of the given option. Numbers of
+// any type are accepted for int options as long as they are integral, and
+// strings are parsed for bool and int options.
+func convertOption(o *Option, value interface{}) (interface{}, error) {
+	switch o.Default.(type) {
+	case bool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch v {
+			case "on":
+				return true, nil
+			case "off":
+				return false, nil
+			}
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a bool, got %v", value)
+	case int:
+		var f float64
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			i, err := strconv.Atoi(fmt.Sprint(v))
+			if err != nil {
+				return nil, errors.New("integer out of range")
+			}
+			return i, nil
+		case float32:
+			f = float64(v)
+		case float64:
+			f = v
+		case string:
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("expected an integer, got %q", v)
+			}
+			return i, nil
+		default:
+			return nil, fmt.Errorf("expected an integer, got %v", value)
+		}
+		if f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+			return nil, fmt.Errorf("expected an integer, got %v", f)
+		}
+		return int(f), nil
+	default:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a string, got %v", value)
 	}
 }
+
+// Set validates the given value, converts it to the type of the option and
+// stores it as set by the user. Listeners are notified if the value changed.
+func (s *Settings) Set(name string, value interface{}) error {
+	return s.SetFrom(name, value, SourceUser)
+}
+
+// SetFrom validates the given value, converts it to the type of the option
+// and stores it in the layer for the given source. The value only takes effect
+// if no source with a higher precedence has set the option.
+func (s *Settings) SetFrom(name string, value interface{}, source SettingSource) error {
+	o, ok := options[name]
+	if !ok {
+		return fmt.Errorf("unknown option %q", name)
+	}
+	if source <= SourceDefault || source >= numSettingSources {
+		return fmt.Errorf("invalid source for option %q", name)
+	}
+
+	value, err := convertOption(o, value)
+	if err == nil && o.Validate != nil {
+		err = o.Validate(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for option %q: %v", name, err)
+	}
+
+	old := s.Get(name)
+	s.layers[source][name] = value
+	s.notify(name, old)
+	return nil
+}
+
+// Unset removes the value that the given source set for an option
+func (s *Settings) Unset(name string, source SettingSource) {
+	if source <= SourceDefault || source >= numSettingSources {
+		return
+	}
+	if _, ok := s.layers[source][name]; ok {
+		old := s.Get(name)
+		delete(s.layers[source], name)
+		s.notify(name, old)
+	}
+}
+
+// ClearSource removes all values that were set by the given source
+func (s *Settings) ClearSource(source SettingSource) {
+	if source <= SourceDefault || source >= numSettingSources {
+		return
+	}
+	for name := range s.layers[source] {
+		s.Unset(name, source)
+	}
+}
+
+// notify calls the listeners if the value of the option is no longer old
+func (s *Settings) notify(name string, old interface{}) {
+	if value := s.Get(name); value != old {
+		for _, l := range s.listeners {
+			l(name, old, value)
+		}
+	}
+}
+
+// Source returns the source of the current value of the given option
+func (s *Settings) Source(name string) SettingSource {
+	for source := numSettingSources - 1; source > SourceDefault; source-- {
+		if _, ok := s.layers[source][name]; ok {
+			return source
+		}
+	}
+	return SourceDefault
+}
+
+// Get returns the value of the given option, or nil if there is no such option
+func (s *Settings) Get(name string) interface{} {
+	if source := s.Source(name); source != SourceDefault {
+		return s.layers[source][name]
+	}
+	if o, ok := options[name]; ok {
+		return o.Default
+	}
+	return nil
+}
+
+// Bool returns the value of the given bool option, or false if it is not a
+// bool option
+func (s *Settings) Bool(name string) bool {
+	v, _ := s.Get(name).(bool)
+	return v
+}
+
+// Int returns the value of the given int option, or 0 if it is not an int
+// option
+func (s *Settings) Int(name string) int {
+	v, _ := s.Get(name).(int)
+	return v
+}
+
+// String returns the value of the given string option, or "" if it is not a
+// string option
+func (s *Settings) String(name string) string {
+	v, _ := s.Get(name).(string)
+	return v
+}
+
+// OnChange adds a listener that is called whenever the value of an option
+// changes
+func (s *Settings) OnChange(l SettingsListener) {
+	s.listeners = append(s.listeners, l)
+}
@@ -0,0 +1,138 @@
+package femto
+
+import "testing"
+
+func TestSettingsSetFrom(t *testing.T) {
+	cases := []struct {
+		name   string
+		option string
+		value  interface{}
+		want   interface{}
+		err    bool
+	}{
+		{name: "bool", option: "syntax", value: false, want: false},
+		{name: "bool from on", option: "syntax", value: "on", want: true},
+		{name: "bool from off", option: "syntax", value: "off", want: false},
+		{name: "bool from string", option: "syntax", value: "false", want: false},
+		{name: "bool from number", option: "syntax", value: 1, err: true},
+		{name: "int", option: "tabsize", value: 8, want: 8},
+		{name: "int from int64", option: "tabsize", value: int64(2), want: 2},
+		{name: "int from float", option: "tabsize", value: 3.0, want: 3},
+		{name: "int from fraction", option: "tabsize", value: 3.5, err: true},
+		{name: "int from string", option: "tabsize", value: "6", want: 6},
+		{name: "int from word", option: "tabsize", value: "six", err: true},
+		{name: "int below minimum", option: "tabsize", value: 0, err: true},
+		{name: "string", option: "fileformat", value: "dos", want: "dos"},
+		{name: "string from number", option: "fileformat", value: 1, err: true},
+		{name: "string not a choice", option: "fileformat", value: "mac", err: true},
+		{name: "unknown option", option: "nosuchoption", value: true, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := DefaultLocalSettings()
+			before := s.Get(c.option)
+
+			err := s.SetFrom(c.option, c.value, SourceGlobal)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got value %v", s.Get(c.option))
+				}
+				if got := s.Get(c.option); got != before {
+					t.Errorf("value changed to %v after an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := s.Get(c.option); got != c.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, c.want, c.want)
+			}
+		})
+	}
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	type set struct {
+		source SettingSource
+		value  int
+	}
+
+	cases := []struct {
+		name   string
+		sets   []set
+		unset  []SettingSource
+		want   int
+		source SettingSource
+	}{
+		{name: "default", want: 4, source: SourceDefault},
+		{
+			name:   "global over detected",
+			sets:   []set{{SourceGlobal, 2}, {SourceDetected, 8}},
+			want:   2,
+			source: SourceGlobal,
+		},
+		{
+			name:   "file over filetype",
+			sets:   []set{{SourceFile, 3}, {SourceFiletype, 2}},
+			want:   3,
+			source: SourceFile,
+		},
+		{
+			name:   "modeline over editorconfig",
+			sets:   []set{{SourceModeline, 3}, {SourceEditorConfig, 2}},
+			want:   3,
+			source: SourceModeline,
+		},
+		{
+			name:   "user over everything",
+			sets:   []set{{SourceUser, 5}, {SourceModeline, 3}, {SourceGlobal, 2}},
+			want:   5,
+			source: SourceUser,
+		},
+		{
+			name:   "unset falls back",
+			sets:   []set{{SourceEditorConfig, 2}, {SourceUser, 5}},
+			unset:  []SettingSource{SourceUser},
+			want:   2,
+			source: SourceEditorConfig,
+		},
+		{
+			name:   "unset all",
+			sets:   []set{{SourceGlobal, 2}},
+			unset:  []SettingSource{SourceGlobal},
+			want:   4,
+			source: SourceDefault,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := DefaultLocalSettings()
+			var changes []interface{}
+			s.OnChange(func(name string, old, new interface{}) {
+				changes = append(changes, new)
+			})
+
+			for _, set := range c.sets {
+				if err := s.SetFrom("tabsize", set.value, set.source); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			for _, source := range c.unset {
+				s.Unset("tabsize", source)
+			}
+
+			if got := s.Int("tabsize"); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			if got := s.Source("tabsize"); got != c.source {
+				t.Errorf("got source %v, want %v", got, c.source)
+			}
+			if len(changes) > 0 && changes[len(changes)-1] != c.want {
+				t.Errorf("last change notified %v, want %v", changes[len(changes)-1], c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package femto
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// SplitView is a tview primitive that arranges several views in splits. Each
+// split is a view with its own cursors and scroll position, so the same
+// buffer can be edited in several places at once.
+type SplitView struct {
+	*tview.Box
+
+	root *splitNode
+
+	// The view that receives key events
+	current *View
+}
+
+// A splitNode is either a view or a list of splits that are laid out side by
+// side (vertical) or stacked (horizontal)
+type splitNode struct {
+	view     *View
+	vertical bool
+	children []*splitNode
+	parent   *splitNode
+}
+
+// NewSplitView returns a new split view that initially contains only the
+// given view.
+func NewSplitView(v *View) *SplitView {
+	return &SplitView{
+		Box:     tview.NewBox(),
+		root:    &splitNode{view: v},
+		current: v,
+	}
+}
+
+// Current returns the view that receives key events.
+func (s *SplitView) Current() *View {
+	return s.current
+}
+
+// SetCurrent makes the given view the one that receives key events.
+func (s *SplitView) SetCurrent(v *View) {
+	if s.find(s.root, v) != nil {
+		s.current = v
+	}
+}
+
+// Views returns the views in the order in which they are laid out.
+func (s *SplitView) Views() []*View {
+	var views []*View
+	s.walk(s.root, func(n *splitNode) {
+		views = append(views, n.view)
+	})
+	return views
+}
+
+// walk calls f for each view in the tree rooted at n
+func (s *SplitView) walk(n *splitNode, f func(n *splitNode)) {
+	if n.view != nil {
+		f(n)
+		return
+	}
+	for _, c := range n.children {
+		s.walk(c, f)
+	}
+}
+
+// find returns the node of the given view
+func (s *SplitView) find(n *splitNode, v *View) *splitNode {
+	var found *splitNode
+	s.walk(n, func(n *splitNode) {
+		if n.view == v {
+			found = n
+		}
+	})
+	return found
+}
+
+// VSplit splits the current view into two side by side views of the same
+// buffer and makes the new view current. The new view is opened to the right
+// if the splitright option is on.
+func (s *SplitView) VSplit() *View {
+	return s.split(true, s.current.Buf.Settings.Bool("splitright"))
+}
+
+// HSplit splits the current view into two stacked views of the same buffer
+// and makes the new view current. The new view is opened below if the
+// splitbottom option is on.
+func (s *SplitView) HSplit() *View {
+	return s.split(false, s.current.Buf.Settings.Bool("splitbottom"))
+}
+
+// split opens a new view of the current buffer next to the current view
+func (s *SplitView) split(vertical, after bool) *View {
+	cur := s.current
+	v := NewView(cur.Buf)
+	v.Readonly = cur.Readonly
+	v.bindings = cur.bindings
+	v.colorscheme = cur.colorscheme
+	v.fullColorscheme, v.colors = cur.fullColorscheme, cur.colors
+	v.runtimeFiles = cur.runtimeFiles
+	v.signProviders = append([]SignProvider(nil), cur.signProviders...)
+	v.helpText = cur.helpText
+	v.Topline = cur.Topline
+	v.Cursor.GotoLoc(cur.Cursor.Loc)
+
+	n := s.find(s.root, cur)
+	leaf := &splitNode{view: v}
+	if n.parent == nil || n.parent.vertical != vertical {
+		// Replace the view with a split of the view and the new view
+		n.children = []*splitNode{{view: cur, parent: n}}
+		n.view, n.vertical = nil, vertical
+		n = n.children[0]
+	}
+
+	parent := n.parent
+	leaf.parent = parent
+	i := 0
+	for parent.children[i] != n {
+		i++
+	}
+	if after {
+		i++
+	}
+	parent.children = append(parent.children, nil)
+	copy(parent.children[i+1:], parent.children[i:])
+	parent.children[i] = leaf
+
+	s.current = v
+	return v
+}
+
+// Close removes the given view from the splits. The last view cannot be
+// closed. If the view was current, the view that takes its place becomes
+// current.
+func (s *SplitView) Close(v *View) bool {
+	n := s.find(s.root, v)
+	if n == nil || n.parent == nil {
+		return false
+	}
+
+	parent := n.parent
+	i := 0
+	for parent.children[i] != n {
+		i++
+	}
+	parent.children = append(parent.children[:i], parent.children[i+1:]...)
+	if len(parent.children) == 1 {
+		// A split of a single node is replaced by the node
+		only := parent.children[0]
+		parent.view, parent.vertical, parent.children = only.view, only.vertical, only.children
+		for _, c := range parent.children {
+			c.parent = parent
+		}
+	}
+	v.Close()
+
+	if s.current == v {
+		if i >= len(parent.children) {
+			i = len(parent.children) - 1
+		}
+		next := parent
+		if parent.view == nil {
+			next = parent.children[i]
+		}
+		for next.view == nil {
+			next = next.children[0]
+		}
+		s.current = next.view
+	}
+	return true
+}
+
+// NextSplit makes the view after the current one current.
+func (s *SplitView) NextSplit() {
+	s.cycle(1)
+}
+
+// PreviousSplit makes the view before the current one current.
+func (s *SplitView) PreviousSplit() {
+	s.cycle(-1)
+}
+
+// cycle moves the current view forward or backward in the layout order
+func (s *SplitView) cycle(dir int) {
+	views := s.Views()
+	for i, v := range views {
+		if v == s.current {
+			s.current = views[(i+dir+len(views))%len(views)]
+			return
+		}
+	}
+}
+
+// SetColorscheme sets the colorscheme for all views.
+func (s *SplitView) SetColorscheme(colorscheme Colorscheme) {
+	for _, v := range s.Views() {
+		v.SetColorscheme(colorscheme)
+	}
+}
+
+// SetRuntimeFiles sets the runtime files for all views.
+func (s *SplitView) SetRuntimeFiles(runtimeFiles *RuntimeFiles) {
+	for _, v := range s.Views() {
+		v.SetRuntimeFiles(runtimeFiles)
+	}
+}
+
+// Focus is called when this primitive receives focus.
+func (s *SplitView) Focus(delegate func(p tview.Primitive)) {
+	delegate(s.current)
+}
+
+// HasFocus returns whether or not this primitive has focus.
+func (s *SplitView) HasFocus() bool {
+	for _, v := range s.Views() {
+		if v.HasFocus() {
+			return true
+		}
+	}
+	return false
+}
+
+// InputHandler returns a handler which passes key events to the current view.
+func (s *SplitView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return s.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		v := s.current
+		if !v.HasFocus() {
+			// The current view was changed by NextSplit or Close
+			setFocus(v)
+		}
+		if handler := v.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// MouseHandler returns a handler which passes mouse events to the view under
+// the mouse. Clicking on a view makes it current.
+func (s *SplitView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return s.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !s.InRect(event.Position()) {
+			return false, nil
+		}
+		for _, v := range s.Views() {
+			if consumed, capture = v.MouseHandler()(action, event, setFocus); consumed {
+				if action == tview.MouseLeftDown {
+					s.current = v
+				}
+				return
+			}
+		}
+		return
+	})
+}
+
+// layout sets the rectangles of the views in the tree rooted at n and draws
+// the separators between side by side splits
+func (s *SplitView) layout(screen tcell.Screen, n *splitNode, x, y, width, height int) {
+	if n.view != nil {
+		n.view.SetRect(x, y, width, height)
+		return
+	}
+
+	count := len(n.children)
+	if n.vertical {
+		// One column between each pair of views is used by a separator
+		avail := width - (count - 1)
+		for i, c := range n.children {
+			w := avail / count
+			if i < avail%count {
+				w++
+			}
+			s.layout(screen, c, x, y, w, height)
+			x += w
+			if i < count-1 {
+				for row := y; row < y+height; row++ {
+					screen.SetContent(x, row, '│', nil, s.current.colorscheme.defaultStyle())
+				}
+				x++
+			}
+		}
+		return
+	}
+
+	for i, c := range n.children {
+		h := height / count
+		if i < height%count {
+			h++
+		}
+		s.layout(screen, c, x, y, width, h)
+		y += h
+	}
+}
+
+// Draw draws the views in their splits.
+func (s *SplitView) Draw(screen tcell.Screen) {
+	s.Box.Draw(screen)
+	x, y, width, height := s.GetInnerRect()
+	s.layout(screen, s.root, x, y, width, height)
+
+	// Draw the current view last so that it owns the terminal cursor
+	for _, v := range s.Views() {
+		if v != s.current {
+			v.Draw(screen)
+		}
+	}
+	s.current.Draw(screen)
+}
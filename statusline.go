@@ -0,0 +1,138 @@
+package femto
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// A StatusSegment returns the text of a custom status line placeholder
+type StatusSegment func(v *View) string
+
+var statusSegments = map[string]StatusSegment{}
+
+// RegisterStatusSegment adds a placeholder $(name) to the status line format
+// whose text is computed by the given function. Registering a segment with the
+// name of an existing one replaces it.
+func RegisterStatusSegment(name string, segment StatusSegment) {
+	statusSegments[name] = segment
+}
+
+// statusPlaceholder matches a placeholder such as $(line) or $(opt:tabsize)
+var statusPlaceholder = regexp.MustCompile(`\$\(([^)]+)\)`)
+
+// SetHelpText sets the text that is displayed for the $(help) placeholder of
+// the status line, for example a list of the application's key bindings
+func (v *View) SetHelpText(help string) {
+	v.helpText = help
+}
+
+// statusSegment returns the text for the status line placeholder with the
+// given name
+func (v *View) statusSegment(name string) string {
+	if strings.HasPrefix(name, "opt:") {
+		if value := v.Buf.Settings.Get(name[len("opt:"):]); value != nil {
+			switch value := value.(type) {
+			case bool:
+				return strconv.FormatBool(value)
+			case int:
+				return strconv.Itoa(value)
+			case string:
+				return value
+			}
+		}
+		return ""
+	}
+
+	switch name {
+	case "filename":
+		return v.Buf.GetName()
+	case "modified":
+		if v.Buf.Modified() {
+			return "+ "
+		}
+		return ""
+	case "line":
+		return strconv.Itoa(v.Cursor.Y + 1)
+	case "col":
+		return strconv.Itoa(v.Cursor.X + 1)
+	case "mode":
+		if v.isOverwriteMode {
+			return "OVR"
+		}
+		return "INS"
+	case "help":
+		if v.Buf.Settings.Bool("hidehelp") {
+			return ""
+		}
+		return v.helpText
+	}
+
+	if segment, ok := statusSegments[name]; ok {
+		return segment(v)
+	}
+	return ""
+}
+
+// formatStatus expands the placeholders in a status line format string
+func (v *View) formatStatus(format string) string {
+	return statusPlaceholder.ReplaceAllStringFunc(format, func(p string) string {
+		return v.statusSegment(p[2 : len(p)-1])
+	})
+}
+
+// statusLineHeight returns the number of rows at the bottom of the view that
+// are used by the status line
+func (v *View) statusLineHeight() int {
+	// The status line is only shown if a row of text fits above it
+	_, _, _, height := v.Box.GetInnerRect()
+	if height > 1 && v.Buf != nil && v.Buf.Settings.Bool("statusline") {
+		return 1
+	}
+	return 0
+}
+
+// displayStatusLine draws the status line on the row below the text area. The
+// left format is aligned to the left edge and the right format to the right.
+func (v *View) displayStatusLine(screen tcell.Screen) {
+	if v.statusLineHeight() == 0 {
+		return
+	}
+
+	style := v.colorscheme.defaultStyle().Reverse(true)
+	if s, ok := v.colorscheme["statusline"]; ok {
+		style = s
+	}
+
+	// The status line also spans the minimap
+	width := v.width + v.minimapWidth()
+	y := v.y + v.height
+	for x := v.x; x < v.x+width; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+
+	left := v.formatStatus(v.Buf.Settings.String("statusformatl"))
+	right := v.formatStatus(v.Buf.Settings.String("statusformatr"))
+
+	x := v.x
+	for _, r := range left {
+		if x >= v.x+width {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += runewidth.RuneWidth(r)
+	}
+
+	// The right side is only drawn if it does not overlap the left side
+	x = v.x + width - runewidth.StringWidth(right)
+	if x <= v.x+runewidth.StringWidth(left) {
+		return
+	}
+	for _, r := range right {
+		screen.SetContent(x, y, r, nil, style)
+		x += runewidth.RuneWidth(r)
+	}
+}
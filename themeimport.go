@@ -0,0 +1,532 @@
+package femto
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A themeRule is a TextMate token color rule: the scope selectors it applies
+// to and its foreground, background and fontStyle settings
+type themeRule struct {
+	selectors []string
+	settings  map[string]string
+}
+
+// A theme is an editor theme in the form used by VS Code: colors of the
+// editor's interface by key, and token color rules
+type theme struct {
+	colors map[string]string
+	rules  []themeRule
+}
+
+// themeUIGroups maps colorscheme groups for the editor's interface to the
+// VS Code color keys of their foreground and background
+var themeUIGroups = []struct {
+	group  string
+	fg, bg string
+}{
+	{"line-number", "editorLineNumber.foreground", "editorGutter.background"},
+	{"current-line-number", "editorLineNumber.activeForeground", "editorGutter.background"},
+	{"cursor-line", "", "editor.lineHighlightBackground"},
+	{"color-column", "", "editorRuler.foreground"},
+	{"selection", "editor.selectionForeground", "editor.selectionBackground"},
+	{"whitespace", "editorWhitespace.foreground", ""},
+	{"indent-char", "editorWhitespace.foreground", ""},
+	{"indent-guide", "editorIndentGuide.background", ""},
+	{"indent-guide-active", "editorIndentGuide.activeBackground", ""},
+	{"word-highlight", "", "editor.wordHighlightBackground"},
+	{"fold", "editorGutter.foldingControlForeground", "editor.foldBackground"},
+	{"statusline", "statusBar.foreground", "statusBar.background"},
+	{"tabbar", "tab.inactiveForeground", "editorGroupHeader.tabsBackground"},
+	{"tabbar.active", "tab.activeForeground", "tab.activeBackground"},
+	{"minimap", "", "minimap.background"},
+	{"minimap-viewport", "", "minimapSlider.background"},
+	{"gutter-error", "editorError.foreground", ""},
+	{"gutter-warning", "editorWarning.foreground", ""},
+	{"diff-added", "editorGutter.addedBackground", "diffEditor.insertedTextBackground"},
+	{"diff-modified", "editorGutter.modifiedBackground", ""},
+	{"diff-deleted", "editorGutter.deletedBackground", "diffEditor.removedTextBackground"},
+	{"bracket-1", "editorBracketHighlight.foreground1", ""},
+	{"bracket-2", "editorBracketHighlight.foreground2", ""},
+	{"bracket-3", "editorBracketHighlight.foreground3", ""},
+	{"bracket-4", "editorBracketHighlight.foreground4", ""},
+	{"bracket-5", "editorBracketHighlight.foreground5", ""},
+	{"bracket-6", "editorBracketHighlight.foreground6", ""},
+}
+
+// themeSyntaxGroups maps the highlight groups of the syntax files to typical
+// TextMate scopes of the same kind of token. A group gets the style of the
+// first of its scopes that the theme has rules for.
+var themeSyntaxGroups = []struct {
+	group  string
+	scopes []string
+}{
+	{"comment", []string{"comment.line"}},
+	{"constant", []string{"constant.language"}},
+	{"constant.bool", []string{"constant.language.boolean"}},
+	{"constant.number", []string{"constant.numeric"}},
+	{"constant.specialChar", []string{"constant.character.escape"}},
+	{"constant.string", []string{"string.quoted.double"}},
+	{"constant.string.char", []string{"constant.character", "string.quoted.single"}},
+	{"constant.string.url", []string{"markup.underline.link"}},
+	{"identifier", []string{"entity.name.function", "support.function"}},
+	{"identifier.class", []string{"entity.name.type.class", "entity.name.class"}},
+	{"identifier.macro", []string{"entity.name.function.preprocessor"}},
+	{"identifier.var", []string{"variable.other", "variable"}},
+	{"preproc", []string{"meta.preprocessor", "keyword.control.directive"}},
+	{"special", []string{"constant.other.placeholder", "support.constant"}},
+	{"statement", []string{"keyword.control"}},
+	{"symbol", []string{"keyword.operator"}},
+	{"symbol.brackets", []string{"punctuation.section.block", "meta.brace"}},
+	{"symbol.operator", []string{"keyword.operator"}},
+	{"symbol.tag", []string{"entity.name.tag"}},
+	{"type", []string{"storage.type", "entity.name.type", "support.type"}},
+	{"type.keyword", []string{"storage.modifier"}},
+	{"error", []string{"invalid.illegal", "invalid"}},
+	{"underlined", []string{"markup.underline"}},
+}
+
+// tmThemeColors maps the global settings of a TextMate theme to the VS Code
+// color keys with the same meaning
+var tmThemeColors = map[string]string{
+	"background":       "editor.background",
+	"foreground":       "editor.foreground",
+	"lineHighlight":    "editor.lineHighlightBackground",
+	"selection":        "editor.selectionBackground",
+	"invisibles":       "editorWhitespace.foreground",
+	"gutter":           "editorGutter.background",
+	"gutterForeground": "editorLineNumber.foreground",
+	"guide":            "editorIndentGuide.background",
+	"activeGuide":      "editorIndentGuide.activeBackground",
+}
+
+// ParseVSCodeTheme converts a VS Code color theme in JSON to a colorscheme.
+// The token colors of the theme are mapped onto the highlight groups of the
+// syntax files, and its editor colors onto the groups for the line numbers,
+// the status line, the selection and so on. Comments and trailing commas are
+// allowed in the JSON, as they are by VS Code.
+func ParseVSCodeTheme(data []byte) (Colorscheme, error) {
+	var doc struct {
+		Colors      map[string]string `json:"colors"`
+		TokenColors []struct {
+			Scope    interface{}       `json:"scope"`
+			Settings map[string]string `json:"settings"`
+		} `json:"tokenColors"`
+	}
+	if err := json.Unmarshal(standardJSON(data), &doc); err != nil {
+		return nil, fmt.Errorf("parsing theme: %v", err)
+	}
+
+	t := theme{colors: doc.Colors}
+	if t.colors == nil {
+		t.colors = map[string]string{}
+	}
+	for _, tc := range doc.TokenColors {
+		var selectors []string
+		switch scope := tc.Scope.(type) {
+		case nil:
+			// A rule without a scope holds the default colors
+			setThemeDefaults(t.colors, tc.Settings)
+			continue
+		case string:
+			selectors = strings.Split(scope, ",")
+		case []interface{}:
+			for _, s := range scope {
+				if s, ok := s.(string); ok {
+					selectors = append(selectors, s)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid scope %v", scope)
+		}
+		t.rules = append(t.rules, themeRule{selectors: selectors, settings: tc.Settings})
+	}
+	return t.colorscheme()
+}
+
+// ParseTmTheme converts a TextMate theme, a .tmTheme property list, to a
+// colorscheme in the same way as ParseVSCodeTheme.
+func ParseTmTheme(data []byte) (Colorscheme, error) {
+	plist, err := parsePlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing theme: %v", err)
+	}
+	root, ok := plist.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("parsing theme: not a dictionary")
+	}
+	settings, ok := root["settings"].([]interface{})
+	if !ok {
+		return nil, errors.New("parsing theme: no settings")
+	}
+
+	t := theme{colors: map[string]string{}}
+	for _, s := range settings {
+		dict, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ruleSettings := map[string]string{}
+		if d, ok := dict["settings"].(map[string]interface{}); ok {
+			for k, v := range d {
+				if v, ok := v.(string); ok {
+					ruleSettings[k] = v
+				}
+			}
+		}
+
+		scope, ok := dict["scope"].(string)
+		if !ok {
+			for k, v := range ruleSettings {
+				if key, ok := tmThemeColors[k]; ok {
+					t.colors[key] = v
+				}
+			}
+			continue
+		}
+		t.rules = append(t.rules, themeRule{selectors: strings.Split(scope, ","), settings: ruleSettings})
+	}
+	return t.colorscheme()
+}
+
+// setThemeDefaults sets the default editor colors from the settings of a
+// token color rule without a scope, unless the theme sets them already
+func setThemeDefaults(colors map[string]string, settings map[string]string) {
+	for k, key := range map[string]string{"foreground": "editor.foreground", "background": "editor.background"} {
+		if _, ok := colors[key]; !ok && settings[k] != "" {
+			colors[key] = settings[k]
+		}
+	}
+}
+
+// colorscheme converts the theme to a colorscheme
+func (t theme) colorscheme() (Colorscheme, error) {
+	var firstErr error
+	base := tcell.ColorDefault
+	color := func(s string) (tcell.Color, bool) {
+		if s == "" {
+			return tcell.ColorDefault, false
+		}
+		c, err := parseThemeColor(s, base)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return tcell.ColorDefault, false
+		}
+		return c, true
+	}
+
+	def := tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault)
+	if bg, ok := color(t.colors["editor.background"]); ok {
+		base = bg
+		def = def.Background(bg)
+	}
+	if fg, ok := color(t.colors["editor.foreground"]); ok {
+		def = def.Foreground(fg)
+	}
+
+	c := Colorscheme{"default": def}
+	for _, g := range themeUIGroups {
+		fg, fgOk := color(t.colors[g.fg])
+		bg, bgOk := color(t.colors[g.bg])
+		if !fgOk && !bgOk {
+			continue
+		}
+		style := def
+		if fgOk {
+			style = style.Foreground(fg)
+		}
+		if bgOk {
+			style = style.Background(bg)
+		}
+		c[g.group] = style
+	}
+
+	for _, g := range themeSyntaxGroups {
+		for _, scope := range g.scopes {
+			settings, ok := t.settingsFor(scope)
+			if !ok {
+				continue
+			}
+			style := def
+			if fg, ok := color(settings["foreground"]); ok {
+				style = style.Foreground(fg)
+			}
+			if bg, ok := color(settings["background"]); ok {
+				style = style.Background(bg)
+			}
+			for _, attr := range strings.Fields(settings["fontStyle"]) {
+				if set, ok := styleAttributes[attr]; ok {
+					style = set(style, true)
+				}
+			}
+			c[g.group] = style
+			break
+		}
+	}
+	return c, firstErr
+}
+
+// settingsFor returns the settings of the rules that apply to the given
+// scope, combined the way TextMate does: more specific selectors take
+// precedence over less specific ones, and later rules over earlier ones. It
+// returns false if no rule applies.
+func (t theme) settingsFor(scope string) (map[string]string, bool) {
+	type match struct {
+		specificity int
+		settings    map[string]string
+	}
+	var matches []match
+	for _, r := range t.rules {
+		best := -1
+		for _, sel := range r.selectors {
+			sel = strings.TrimSpace(sel)
+			if sel == "" || strings.ContainsAny(sel, " |&()") {
+				// Selectors that depend on the surrounding scopes are left out
+				continue
+			}
+			if sel == scope || strings.HasPrefix(scope, sel+".") {
+				if n := strings.Count(sel, ".") + 1; n > best {
+					best = n
+				}
+			}
+		}
+		if best >= 0 {
+			matches = append(matches, match{best, r.settings})
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].specificity < matches[j].specificity
+	})
+	settings := map[string]string{}
+	for _, m := range matches {
+		for k, v := range m.settings {
+			settings[k] = v
+		}
+	}
+	return settings, true
+}
+
+// parseThemeColor parses a color in the #RGB, #RGBA, #RRGGBB or #RRGGBBAA
+// format. Translucent colors are blended with the given background color.
+func parseThemeColor(s string, base tcell.Color) (tcell.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long []byte
+		for i := 0; i < len(hex); i++ {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+	if !strings.HasPrefix(s, "#") || len(hex) != 6 && len(hex) != 8 {
+		return tcell.ColorDefault, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return tcell.ColorDefault, fmt.Errorf("invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		return tcell.NewHexColor(int32(v)), nil
+	}
+
+	r, g, b, a := int32(v>>24), int32(v>>16&0xff), int32(v>>8&0xff), int32(v&0xff)
+	if base.Valid() {
+		br, bg, bb := base.RGB()
+		r = (r*a + br*(255-a)) / 255
+		g = (g*a + bg*(255-a)) / 255
+		b = (b*a + bb*(255-a)) / 255
+	}
+	return tcell.NewRGBColor(r, g, b), nil
+}
+
+// standardJSON removes the comments and trailing commas that VS Code allows
+// in JSON files
+func standardJSON(data []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			continue
+		case c == '}' || c == ']':
+			// Drop a comma before the closing bracket
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// parsePlist parses an XML property list into maps, slices and strings.
+// Numbers and dates are returned as strings.
+func parsePlist(data []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return parsePlistValue(d, start)
+		}
+	}
+}
+
+// parsePlistValue parses the property list value that starts with the given
+// element
+func parsePlistValue(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := map[string]interface{}{}
+		key := ""
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				if tok.Name.Local == "key" {
+					if err := d.DecodeElement(&key, &tok); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				v, err := parsePlistValue(d, tok)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = v
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		var array []interface{}
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				v, err := parsePlistValue(d, tok)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, v)
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+	case "true", "false":
+		return start.Name.Local == "true", d.Skip()
+	default:
+		var s string
+		err := d.DecodeElement(&s, &start)
+		return strings.TrimSpace(s), err
+	}
+}
+
+// WriteColorscheme writes a colorscheme in the format read by
+// ParseColorscheme, with one color-link statement per group.
+func WriteColorscheme(w io.Writer, colorscheme Colorscheme) error {
+	groups := make([]string, 0, len(colorscheme))
+	for group := range colorscheme {
+		if group != "default" {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	if _, ok := colorscheme["default"]; ok {
+		// The default style comes first because it is the base of the others
+		groups = append([]string{"default"}, groups...)
+	}
+
+	for _, group := range groups {
+		if _, err := fmt.Fprintf(w, "color-link %s \"%s\"\n", group, styleToString(colorscheme[group])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// styleToString returns the string representation of a style that
+// StringToStyle parses
+func styleToString(style tcell.Style) string {
+	fg, bg, attrs := style.Decompose()
+
+	var s strings.Builder
+	for _, a := range []struct {
+		name string
+		mask tcell.AttrMask
+	}{
+		{"bold", tcell.AttrBold},
+		{"italic", tcell.AttrItalic},
+		{"dim", tcell.AttrDim},
+		{"blink", tcell.AttrBlink},
+		{"reverse", tcell.AttrReverse},
+		{"underline", tcell.AttrUnderline},
+		{"strikethrough", tcell.AttrStrikeThrough},
+	} {
+		if attrs&a.mask != 0 {
+			s.WriteString(a.name + " ")
+		}
+	}
+	s.WriteString(colorToString(fg) + "," + colorToString(bg))
+	return s.String()
+}
+
+// colorToString returns the string representation of a color that
+// StringToColor parses
+func colorToString(c tcell.Color) string {
+	switch {
+	case !c.Valid():
+		return "default"
+	case c.IsRGB():
+		return fmt.Sprintf("#%06X", c.Hex())
+	}
+	return strconv.Itoa(int(c - tcell.ColorValid))
+}
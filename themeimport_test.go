@@ -0,0 +1,117 @@
+package femto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const testVSCodeTheme = `{
+	// Comments and trailing commas are allowed
+	"colors": {
+		"editor.background": "#1e1e1e",
+		"editor.foreground": "#d4d4d4",
+		"editor.lineHighlightBackground": "#ffffff80",
+		"statusBar.background": "#007acc",
+	},
+	"tokenColors": [
+		{"scope": "comment", "settings": {"foreground": "#6a9955", "fontStyle": "italic"}},
+		{"scope": ["string", "constant.numeric"], "settings": {"foreground": "#ce9178"}},
+		{"scope": "string.quoted.double", "settings": {"foreground": "#ff0000"}},
+		{"scope": "source.go keyword.control", "settings": {"foreground": "#00ff00"}},
+		{"scope": "keyword.control, storage.modifier", "settings": {"foreground": "#c586c0", "fontStyle": "bold underline"}},
+	],
+}`
+
+func TestParseVSCodeTheme(t *testing.T) {
+	colorscheme, err := ParseVSCodeTheme([]byte(testVSCodeTheme))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		group string
+		style string
+	}{
+		{"default", "#D4D4D4,#1E1E1E"},
+		// Transparent colors are blended with the editor background
+		{"cursor-line", "#D4D4D4,#8E8E8E"},
+		{"statusline", "#D4D4D4,#007ACC"},
+		{"comment", "italic #6A9955,#1E1E1E"},
+		// The most specific selector wins
+		{"constant.string", "#FF0000,#1E1E1E"},
+		{"constant.string.char", "#CE9178,#1E1E1E"},
+		{"constant.number", "#CE9178,#1E1E1E"},
+		// Selectors with a parent scope are left out
+		{"statement", "bold underline #C586C0,#1E1E1E"},
+		{"type.keyword", "bold underline #C586C0,#1E1E1E"},
+		{"selection", ""},
+		{"identifier", ""},
+	}
+
+	for _, c := range cases {
+		style, ok := colorscheme[c.group]
+		switch {
+		case !ok && c.style != "":
+			t.Errorf("%s: missing", c.group)
+		case ok && c.style == "":
+			t.Errorf("%s: got %q, want no style", c.group, styleToString(style))
+		case ok && styleToString(style) != c.style:
+			t.Errorf("%s: got %q, want %q", c.group, styleToString(style), c.style)
+		}
+	}
+
+	// The written colorscheme is read back unchanged
+	var buf bytes.Buffer
+	if err := WriteColorscheme(&buf, colorscheme); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsed := ParseColorscheme(buf.String())
+	for group, style := range colorscheme {
+		if parsed[group] != style {
+			t.Errorf("%s: read back as %q, want %q", group, styleToString(parsed[group]), styleToString(style))
+		}
+	}
+}
+
+func TestParseVSCodeThemeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		theme string
+	}{
+		{"invalid json", `{"colors": }`},
+		{"named color", `{"colors": {"editor.background": "red"}}`},
+		{"short color", `{"colors": {"editor.background": "#12345"}}`},
+		{"invalid scope", `{"tokenColors": [{"scope": 1, "settings": {}}]}`},
+	}
+
+	for _, c := range cases {
+		if _, err := ParseVSCodeTheme([]byte(c.theme)); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestStyleToString(t *testing.T) {
+	cases := []struct {
+		style tcell.Style
+		want  string
+	}{
+		{tcell.StyleDefault, "default,default"},
+		{tcell.StyleDefault.Foreground(tcell.ColorNavy), "4,default"},
+		{tcell.StyleDefault.Background(tcell.NewHexColor(0x102030)), "default,#102030"},
+		{tcell.StyleDefault.Bold(true).Reverse(true), "bold reverse default,default"},
+		{tcell.StyleDefault.StrikeThrough(true).Italic(true).Foreground(tcell.ColorRed), "italic strikethrough 9,default"},
+	}
+
+	for _, c := range cases {
+		got := styleToString(c.style)
+		if got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+		if back := StringToStyle(got); back != c.style {
+			t.Errorf("%q parsed as %q", got, styleToString(back))
+		}
+	}
+}
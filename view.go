@@ -1,11 +1,11 @@
 package femto
 
 import (
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/rivo/tview"
 )
 
@@ -36,9 +36,22 @@ type View struct {
 	// How much to offset because of line numbers
 	lineNumOffset int
 
+	// The providers for the sign columns in the gutter
+	signProviders []SignProvider
+
 	// The buffer
 	Buf *Buffer
 
+	// The cursors of this view, which are independent of the cursors of
+	// other views of the buffer
+	cursors *cursorSet
+
+	// The byte offset of the cursor in hex mode, which nibble of the byte it
+	// is on and whether it is in the ASCII column
+	hexCursor    int
+	hexLowNibble bool
+	hexASCII     bool
+
 	// We need to keep track of insert key press toggle
 	isOverwriteMode bool
 	lastLoc         Loc
@@ -59,11 +72,35 @@ type View struct {
 	// The keybindings
 	bindings KeyBindings
 
-	// The colorscheme
+	// The colorscheme, downsampled to the number of colors it is drawn with
 	colorscheme Colorscheme
+	// The colorscheme as it was set, and the number of colors it was last
+	// downsampled to
+	fullColorscheme Colorscheme
+	colors          int
+
+	// The colors of the minimap columns of each line, and what they were
+	// computed from
+	minimapColors [][]tcell.Color
+	minimapKey    minimapKey
 
 	// The runtime files
 	runtimeFiles *RuntimeFiles
+
+	// The text displayed for $(help) on the status line
+	helpText string
+
+	// The editor that contains this view, if any
+	editor *Editor
+
+	// The function that redraws the view when it changes without an event
+	redraw func()
+
+	// Where the cursor was when it last moved and when that was, for the
+	// word highlight
+	wordHighlightLoc   Loc
+	wordHighlightTime  time.Time
+	wordHighlightTimer *time.Timer
 }
 
 // NewView returns a new view with the specified buffer.
@@ -90,7 +127,16 @@ func NewView(buf *Buffer) *View {
 // SetRect sets a new position for the view.
 func (v *View) SetRect(x, y, width, height int) {
 	v.Box.SetRect(x, y, width, height)
+	v.updateRect()
+}
+
+// updateRect computes the area in which the text is drawn. The bottom row is
+// reserved for the status line and the right columns for the minimap if they
+// are shown.
+func (v *View) updateRect() {
 	v.x, v.y, v.width, v.height = v.Box.GetInnerRect()
+	v.height -= v.statusLineHeight()
+	v.width -= v.minimapWidth()
 }
 
 // InputHandler returns a handler which received key events when this view has focus,
@@ -112,7 +158,9 @@ func (v *View) SetKeybindings(bindings KeyBindings) {
 
 // SetColorscheme sets the colorscheme for this view.
 func (v *View) SetColorscheme(colorscheme Colorscheme) {
+	v.fullColorscheme = colorscheme
 	v.colorscheme = colorscheme
+	v.colors = 0
 	v.Buf.updateRules(v.runtimeFiles)
 }
 
@@ -123,7 +171,7 @@ func (v *View) SetRuntimeFiles(runtimeFiles *RuntimeFiles) {
 }
 
 func (v *View) paste(clip string) {
-	if v.Buf.Settings["smartpaste"].(bool) {
+	if v.Buf.Settings.Bool("smartpaste") {
 		if v.Cursor.X > 0 && GetLeadingWhitespace(strings.TrimLeft(clip, "\r\n")) == "" {
 			leadingWS := GetLeadingWhitespace(v.Buf.Line(v.Cursor.Y))
 			clip = strings.Replace(clip, "\n", "\n"+leadingWS, -1)
@@ -160,13 +208,27 @@ func (v *View) ScrollDown(n int) {
 	}
 }
 
+// Close is called when the view is no longer used. It releases the view's
+// cursors in its buffer and cancels any redraw that is scheduled for it.
+func (v *View) Close() {
+	v.stopWordHighlightTimer()
+	v.Buf.releaseCursorSet(v.cursors)
+}
+
 // OpenBuffer opens a new buffer in this view.
 // This resets the topline, event handler and cursor.
 func (v *View) OpenBuffer(buf *Buffer) {
+	if v.Buf != nil {
+		v.Buf.releaseCursorSet(v.cursors)
+	}
+	v.stopWordHighlightTimer()
 	v.Buf = buf
+	v.cursors = buf.claimCursorSet()
+	buf.cursorSet = v.cursors
 	v.Cursor = &buf.Cursor
 	v.Topline = 0
 	v.leftCol = 0
+	v.hexCursor, v.hexLowNibble, v.hexASCII = 0, false, false
 	v.Cursor.ResetSelection()
 	v.Relocate()
 	v.Center()
@@ -181,47 +243,43 @@ func (v *View) OpenBuffer(buf *Buffer) {
 // but if softwrap is enabled things get complicated since one buffer
 // line can take up multiple lines in the view
 func (v *View) Bottomline() int {
-	if !v.Buf.Settings["softwrap"].(bool) {
-		return v.Topline + v.height
-	}
-
-	screenX, screenY := 0, 0
-	numLines := 0
-	for lineN := v.Topline; lineN < v.Topline+v.height; lineN++ {
-		line := v.Buf.Line(lineN)
-
-		colN := 0
-		for _, ch := range line {
-			if screenX >= v.width-v.lineNumOffset {
-				screenX = 0
-				screenY++
-			}
+	if !v.Buf.Settings.Bool("softwrap") {
+		if len(v.cellview.fillers) == 0 {
+			return v.Buf.lineAfterRows(v.Topline, v.height)
+		}
 
-			if ch == '\t' {
-				screenX += int(v.Buf.Settings["tabsize"].(float64)) - 1
+		rows := -v.cellview.fillerSkip
+		lineN := v.Topline
+		for ; lineN < v.Buf.NumLines; lineN = v.Buf.nextVisibleLine(lineN) {
+			rows += v.cellview.fillers[lineN] + 1
+			if rows > v.height {
+				break
 			}
-
-			screenX++
-			colN++
 		}
-		screenX = 0
-		screenY++
-		numLines++
+		return lineN
+	}
 
-		if screenY >= v.height {
-			break
-		}
+	screenY := 0
+	lineN := v.Topline
+	for screenY < v.height {
+		screenY += len(v.Buf.wrapLine(lineN, v.width-v.lineNumOffset))
+		lineN = v.Buf.nextVisibleLine(lineN)
 	}
-	return numLines + v.Topline
+	return lineN
 }
 
 // Relocate moves the view window so that the cursor is in view
 // This is useful if the user has scrolled far away, and then starts typing
 func (v *View) Relocate() bool {
+	// Unfold the lines the cursor moved into
+	for v.Buf.IsLineHidden(v.Cursor.Y) {
+		v.Buf.OpenFold(v.Cursor.Y)
+	}
+
 	height := v.Bottomline() - v.Topline
 	ret := false
 	cy := v.Cursor.Y
-	scrollmargin := int(v.Buf.Settings["scrollmargin"].(float64))
+	scrollmargin := v.Buf.Settings.Int("scrollmargin")
 	if cy < v.Topline+scrollmargin && cy > scrollmargin-1 {
 		v.Topline = cy - scrollmargin
 		ret = true
@@ -237,7 +295,7 @@ func (v *View) Relocate() bool {
 		ret = true
 	}
 
-	if !v.Buf.Settings["softwrap"].(bool) {
+	if !v.Buf.Settings.Bool("softwrap") {
 		cx := v.Cursor.GetVisualX()
 		if cx < v.leftCol {
 			v.leftCol = cx
@@ -251,25 +309,50 @@ func (v *View) Relocate() bool {
 	return ret
 }
 
+// editingActions are the actions that change the contents of the buffer,
+// which are not run in readonly views
+var editingActions = map[string]bool{
+	ActionDeleteWordRight:    true,
+	ActionDeleteWordLeft:     true,
+	ActionInsertNewline:      true,
+	ActionInsertSpace:        true,
+	ActionBackspace:          true,
+	ActionDelete:             true,
+	ActionInsertTab:          true,
+	ActionCut:                true,
+	ActionCutLine:            true,
+	ActionDuplicateLine:      true,
+	ActionDeleteLine:         true,
+	ActionMoveLinesUp:        true,
+	ActionMoveLinesDown:      true,
+	ActionIndentSelection:    true,
+	ActionOutdentSelection:   true,
+	ActionOutdentLine:        true,
+	ActionPaste:              true,
+	ActionDiffRevertHunk:     true,
+	ActionConflictTakeOurs:   true,
+	ActionConflictTakeTheirs: true,
+	ActionConflictTakeBoth:   true,
+}
+
+// isEditingAction returns true if the given action changes the contents of
+// the buffer
+func isEditingAction(action func(*View) bool) bool {
+	funcName := ShortFuncName(action)
+	return editingActions[funcName[strings.LastIndex(funcName, ".")+1:]]
+}
+
 // Execute actions executes the supplied actions
 func (v *View) ExecuteActions(actions []func(*View) bool) bool {
 	relocate := false
-	readonlyBindingsList := []string{"Delete", "Insert", "Backspace", "Cut", "Play", "Paste", "Move", "Add", "DuplicateLine", "Macro"}
 	for _, action := range actions {
-		readonlyBindingsResult := false
-		funcName := ShortFuncName(action)
-		if v.Readonly == true {
-			// check for readonly and if true only let key bindings get called if they do not change the contents.
-			for _, readonlyBindings := range readonlyBindingsList {
-				if strings.Contains(funcName, readonlyBindings) {
-					readonlyBindingsResult = true
-				}
-			}
-		}
-		if !readonlyBindingsResult {
-			// call the key binding
-			relocate = action(v) || relocate
+		// Only let key bindings get called in readonly views if they do not
+		// change the contents
+		if v.Readonly && isEditingAction(action) {
+			continue
 		}
+		// call the key binding
+		relocate = action(v) || relocate
 	}
 
 	return relocate
@@ -288,6 +371,13 @@ func (v *View) SetCursor(c *Cursor) bool {
 
 // HandleEvent handles an event passed by the main loop
 func (v *View) HandleEvent(event tcell.Event) {
+	v.activateCursors()
+
+	if e, ok := event.(*tcell.EventKey); ok && v.Buf.Binary() {
+		v.handleHexEvent(e)
+		return
+	}
+
 	// This bool determines whether the view is relocated at the end of the function
 	// By default it's true because most events should cause a relocate
 	relocate := true
@@ -363,19 +453,14 @@ func (v *View) mainCursor() bool {
 
 // displayView draws the view to the screen
 func (v *View) displayView(screen tcell.Screen) {
-	if v.Buf.Settings["softwrap"].(bool) && v.leftCol != 0 {
+	if v.Buf.Settings.Bool("softwrap") && v.leftCol != 0 {
 		v.leftCol = 0
 	}
 
-	// We need to know the string length of the largest line number
-	// so we can pad appropriately when displaying line numbers
-	maxLineNumLength := len(strconv.Itoa(v.Buf.NumLines))
-
-	if v.Buf.Settings["ruler"] == true {
-		// + 1 for the little space after the line number
-		v.lineNumOffset = maxLineNumLength + 1
-	} else {
-		v.lineNumOffset = 0
+	gutterProviders := v.gutterProviders()
+	v.lineNumOffset = len(gutterProviders)
+	if v.Buf.Settings.Bool("ruler") {
+		v.lineNumOffset += v.rulerWidth()
 	}
 
 	xOffset := v.x + v.lineNumOffset
@@ -384,76 +469,72 @@ func (v *View) displayView(screen tcell.Screen) {
 	height := v.height
 	width := v.width
 	left := v.leftCol
+	v.Topline = v.Buf.visibleLine(v.Topline)
 	top := v.Topline
 
 	v.cellview.Draw(v.Buf, v.colorscheme, top, height, left, width-v.lineNumOffset)
 
+	bottom := v.Buf.lineAfterRows(top, height)
+	decorations := append(v.wordHighlights(top, bottom), v.Buf.Decorations(top, bottom)...)
+
 	screenX := v.x
-	realLineN := top - 1
-	visualLineN := 0
-	var line []*Char
-	for visualLineN, line = range v.cellview.lines {
-		var firstChar *Char
-		if len(line) > 0 {
-			firstChar = line[0]
+	for visualLineN, line := range v.cellview.lines {
+		realLineN := v.cellview.rowLines[visualLineN]
+		if realLineN < 0 {
+			v.displayFiller(screen, yOffset+visualLineN)
+			continue
 		}
 
-		var softwrapped bool
-		if firstChar != nil {
-			if firstChar.realLoc.Y == realLineN {
-				softwrapped = true
-			}
-			realLineN = firstChar.realLoc.Y
-		} else {
-			realLineN++
-		}
+		softwrapped := visualLineN > 0 && v.cellview.rowLines[visualLineN-1] == realLineN
 
-		colorcolumn := int(v.Buf.Settings["colorcolumn"].(float64))
+		colorcolumn := v.Buf.Settings.Int("colorcolumn")
 		if colorcolumn != 0 && xOffset+colorcolumn-v.leftCol < v.width {
 			style := v.colorscheme.GetColor("color-column")
 			fg, _, _ := style.Decompose()
-			st := defStyle.Background(fg)
+			st := v.colorscheme.defaultStyle().Background(fg)
 			screen.SetContent(xOffset+colorcolumn-v.leftCol, yOffset+visualLineN, ' ', nil, st)
 		}
 
-		screenX = v.x
+		screenX = v.displayGutter(screen, gutterProviders, v.x, yOffset+visualLineN, realLineN, softwrapped && visualLineN != 0)
 
-		lineNumStyle := defStyle
-		if v.Buf.Settings["ruler"] == true {
-			// Write the line number
-			if style, ok := v.colorscheme["line-number"]; ok {
-				lineNumStyle = style
+		if softwrapped {
+			// Draw the continuation marker at the end of the wrap indentation.
+			// There is no room for it on rows whose wrap indentation was
+			// dropped because it was too wide.
+			marker := v.Buf.Settings.String("wrapmarker")
+			x := xOffset + v.cellview.rowIndents[visualLineN] - runewidth.StringWidth(marker)
+			if x < xOffset {
+				marker = ""
 			}
-			if style, ok := v.colorscheme["current-line-number"]; ok {
-				if realLineN == v.Cursor.Y && !v.Cursor.HasSelection() {
-					lineNumStyle = style
-				}
+			for _, r := range marker {
+				screen.SetContent(x, yOffset+visualLineN, r, nil, whitespaceStyle(v.colorscheme, v.colorscheme.defaultStyle()))
+				x += runewidth.RuneWidth(r)
 			}
+		}
 
-			lineNum := strconv.Itoa(realLineN + 1)
+		if v.Buf.Settings.Bool("ruler") {
+			lineNumStyle := v.lineNumberStyle(realLineN)
+			lineNum := v.lineNumber(realLineN)
+			if softwrapped && visualLineN != 0 {
+				// Pad without the line number because it was written on the visual line before
+				lineNum = ""
+			}
 
-			// Write the spaces before the line number if necessary
-			for i := 0; i < maxLineNumLength-len(lineNum); i++ {
+			// Right-align the line number and pad it to the ruler width
+			rulerEnd := screenX + v.rulerWidth()
+			numEnd := rulerEnd - v.Buf.Settings.Int("rulerpadding")
+			for screenX < numEnd-len(lineNum) {
 				screen.SetContent(screenX, yOffset+visualLineN, ' ', nil, lineNumStyle)
 				screenX++
 			}
-			if softwrapped && visualLineN != 0 {
-				// Pad without the line number because it was written on the visual line before
-				for range lineNum {
-					screen.SetContent(screenX, yOffset+visualLineN, ' ', nil, lineNumStyle)
-					screenX++
-				}
-			} else {
-				// Write the actual line number
-				for _, ch := range lineNum {
-					screen.SetContent(screenX, yOffset+visualLineN, ch, nil, lineNumStyle)
-					screenX++
-				}
+			for _, ch := range lineNum {
+				screen.SetContent(screenX, yOffset+visualLineN, ch, nil, lineNumStyle)
+				screenX++
+			}
+			for screenX < rulerEnd {
+				screen.SetContent(screenX, yOffset+visualLineN, ' ', nil, lineNumStyle)
+				screenX++
 			}
-
-			// Write the extra space
-			screen.SetContent(screenX, yOffset+visualLineN, ' ', nil, lineNumStyle)
-			screenX++
 		}
 
 		var lastChar *Char
@@ -462,21 +543,31 @@ func (v *View) displayView(screen tcell.Screen) {
 			if char != nil {
 				lineStyle := char.style
 
-				colorcolumn := int(v.Buf.Settings["colorcolumn"].(float64))
+				colorcolumn := v.Buf.Settings.Int("colorcolumn")
 				if colorcolumn != 0 && char.visualLoc.X == colorcolumn {
 					style := v.colorscheme.GetColor("color-column")
 					fg, _, _ := style.Decompose()
 					lineStyle = lineStyle.Background(fg)
 				}
 
+				if style, ok := v.conflictStyle(realLineN); ok {
+					lineStyle = mergeStyle(lineStyle, style)
+				}
+
 				charLoc := char.realLoc
+				for _, d := range decorations {
+					if d.contains(charLoc) {
+						lineStyle = mergeStyle(lineStyle, d.Style)
+					}
+				}
+
 				for _, c := range v.Buf.cursors {
 					v.SetCursor(c)
 					if v.Cursor.HasSelection() &&
 						(charLoc.GreaterEqual(v.Cursor.CurSelection[0]) && charLoc.LessThan(v.Cursor.CurSelection[1]) ||
 							charLoc.LessThan(v.Cursor.CurSelection[0]) && charLoc.GreaterEqual(v.Cursor.CurSelection[1])) {
 						// The current character is selected
-						lineStyle = defStyle.Reverse(true)
+						lineStyle = v.colorscheme.defaultStyle().Reverse(true)
 
 						if style, ok := v.colorscheme["selection"]; ok {
 							lineStyle = style
@@ -485,7 +576,7 @@ func (v *View) displayView(screen tcell.Screen) {
 				}
 				v.SetCursor(&v.Buf.Cursor)
 
-				if v.Buf.Settings["cursorline"].(bool) &&
+				if v.Buf.Settings.Bool("cursorline") &&
 					!v.Cursor.HasSelection() && v.Cursor.Y == realLineN {
 					style := v.colorscheme.GetColor("cursor-line")
 					fg, _, _ := style.Decompose()
@@ -544,7 +635,7 @@ func (v *View) displayView(screen tcell.Screen) {
 			(realLoc.GreaterEqual(v.Cursor.CurSelection[0]) && realLoc.LessThan(v.Cursor.CurSelection[1]) ||
 				realLoc.LessThan(v.Cursor.CurSelection[0]) && realLoc.GreaterEqual(v.Cursor.CurSelection[1])) {
 			// The current character is selected
-			selectStyle := defStyle.Reverse(true)
+			selectStyle := v.colorscheme.defaultStyle().Reverse(true)
 
 			if style, ok := v.colorscheme["selection"]; ok {
 				selectStyle = style
@@ -552,7 +643,7 @@ func (v *View) displayView(screen tcell.Screen) {
 			screen.SetContent(xOffset+visualLoc.X, yOffset+visualLoc.Y, ' ', nil, selectStyle)
 		}
 
-		if v.Buf.Settings["cursorline"].(bool) &&
+		if v.Buf.Settings.Bool("cursorline") &&
 			!v.Cursor.HasSelection() && v.Cursor.Y == realLineN {
 			for i := lastX; i < xOffset+v.width-v.lineNumOffset; i++ {
 				style := v.colorscheme.GetColor("cursor-line")
@@ -563,6 +654,22 @@ func (v *View) displayView(screen tcell.Screen) {
 				}
 			}
 		}
+
+		if v.Buf.Settings.Bool("indentguides") {
+			v.displayIndentGuides(screen, visualLineN, lastX, yOffset+visualLineN)
+		}
+
+		if (lastChar != nil || len(line) == 0) && realLoc.X == Count(v.Buf.Line(realLineN)) &&
+			visualLoc.X < v.width-v.lineNumOffset {
+			x, y := xOffset+visualLoc.X, yOffset+visualLoc.Y
+			if v.Buf.Settings.Bool("showeol") {
+				// Draw the end of line marker over whatever background is already there
+				_, _, style, _ := screen.GetContent(x, y)
+				screen.SetContent(x, y, whitespaceGlyph(v.Buf, "eolchar"), nil, whitespaceStyle(v.colorscheme, style))
+				x++
+			}
+			v.displayFoldMarker(screen, x, y, realLineN)
+		}
 	}
 }
 
@@ -581,15 +688,24 @@ func ShowMultiCursor(screen tcell.Screen, x, y, i int) {
 // Draw renders the view and the cursor
 func (v *View) Draw(screen tcell.Screen) {
 	v.Box.Draw(screen)
-	v.x, v.y, v.width, v.height = v.Box.GetInnerRect()
+	v.updateRect()
+	v.activateCursors()
+	v.updateColorDepth(screen)
 
 	// TODO(pdg): just clear from the last line down.
 	for y := v.y; y < v.y+v.height; y++ {
 		for x := v.x; x < v.x+v.width; x++ {
-			screen.SetContent(x, y, ' ', nil, defStyle)
+			screen.SetContent(x, y, ' ', nil, v.colorscheme.defaultStyle())
 		}
 	}
 
+	v.displayStatusLine(screen)
+
+	if v.Buf.Binary() {
+		v.displayHex(screen)
+		return
+	}
+
 	v.displayView(screen)
 
 	// Don't draw the cursor if it is out of the viewport or if it has a selection
@@ -597,7 +713,9 @@ func (v *View) Draw(screen tcell.Screen) {
 		screen.HideCursor()
 	}
 
-	if v.Buf.Settings["scrollbar"].(bool) {
+	if v.Buf.Settings.Bool("scrollbar") {
 		v.scrollbar.Display(screen)
 	}
+
+	v.displayMinimap(screen)
 }
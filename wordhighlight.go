@@ -0,0 +1,109 @@
+package femto
+
+import (
+	"time"
+	"unicode"
+)
+
+// SetRedrawFunc sets the function that the view calls when it needs to be
+// redrawn without an event, for example to show the word highlight once the
+// cursor has rested. In a tview application this is typically app.Draw. The
+// function may be called from another goroutine.
+func (v *View) SetRedrawFunc(redraw func()) {
+	v.redraw = redraw
+}
+
+// wordHighlightReady returns true if the cursor has rested long enough for
+// the other occurrences of the word under it to be highlighted. If the cursor
+// moved, a redraw is scheduled for when the delay has passed.
+func (v *View) wordHighlightReady() bool {
+	delay := time.Duration(v.Buf.Settings.Int("wordhighlightdelay")) * time.Millisecond
+	if v.Cursor.Loc != v.wordHighlightLoc {
+		v.wordHighlightLoc = v.Cursor.Loc
+		v.wordHighlightTime = time.Now()
+		v.stopWordHighlightTimer()
+		if v.redraw != nil && delay > 0 {
+			v.wordHighlightTimer = time.AfterFunc(delay, v.redraw)
+		}
+	}
+	return time.Since(v.wordHighlightTime) >= delay
+}
+
+// stopWordHighlightTimer cancels the redraw that is scheduled for when the
+// cursor has rested, if any
+func (v *View) stopWordHighlightTimer() {
+	if v.wordHighlightTimer != nil {
+		v.wordHighlightTimer.Stop()
+		v.wordHighlightTimer = nil
+	}
+}
+
+// wordUnderCursor returns the identifier under or just before the cursor, or
+// nil if there is none
+func (v *View) wordUnderCursor() []rune {
+	line := v.Buf.LineRunes(v.Cursor.Y)
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(line) && IsWordChar(string(line[i]))
+	}
+
+	x := v.Cursor.X
+	if !isWord(x) {
+		if !isWord(x - 1) {
+			return nil
+		}
+		x--
+	}
+
+	start, end := x, x+1
+	for isWord(start - 1) {
+		start--
+	}
+	for isWord(end) {
+		end++
+	}
+	if unicode.IsDigit(line[start]) {
+		// Numbers are not identifiers
+		return nil
+	}
+	return line[start:end]
+}
+
+// wordHighlights returns decorations for the whole-word occurrences of the
+// word under the cursor in lines [top, bottom), if the wordhighlight option
+// is on and the cursor has rested on a word
+func (v *View) wordHighlights(top, bottom int) []*Decoration {
+	if !v.Buf.Settings.Bool("wordhighlight") || v.Cursor.HasSelection() || !v.wordHighlightReady() {
+		return nil
+	}
+	word := v.wordUnderCursor()
+	if word == nil {
+		return nil
+	}
+
+	style := v.colorscheme.defaultStyle().Underline(true)
+	if s, ok := v.colorscheme["word-highlight"]; ok {
+		style = s
+	}
+
+	var highlights []*Decoration
+	for lineN := top; lineN < bottom && lineN < v.Buf.NumLines; lineN++ {
+		line := v.Buf.LineRunes(lineN)
+		for x := 0; x+len(word) <= len(line); x++ {
+			if x > 0 && IsWordChar(string(line[x-1])) {
+				continue
+			}
+			if end := x + len(word); end < len(line) && IsWordChar(string(line[end])) {
+				continue
+			}
+			if string(line[x:x+len(word)]) == string(word) {
+				highlights = append(highlights, &Decoration{
+					Start: Loc{x, lineN},
+					End:   Loc{x + len(word), lineN},
+					Style: style,
+				})
+				x += len(word) - 1
+			}
+		}
+	}
+	return highlights
+}
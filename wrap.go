@@ -0,0 +1,156 @@
+package femto
+
+import (
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// A wrapRow is one visual row of a soft-wrapped line. It displays the runes
+// [start, end) of the line after indent columns of wrap indentation.
+type wrapRow struct {
+	start, end int
+	indent     int
+}
+
+// runeWidthAt returns the width of a rune displayed at the given column
+func runeWidthAt(r rune, col, tabsize int) int {
+	if r == '\t' {
+		return tabsize - col%tabsize
+	}
+	return runewidth.RuneWidth(r)
+}
+
+// wrapLine splits a line into visual rows of at most width columns. If
+// wordwrap is true, rows are broken after whitespace where possible.
+// Continuation rows are indented by indent columns.
+func wrapLine(line []rune, width, tabsize int, wordwrap bool, indent int) []wrapRow {
+	if indent >= width/2 {
+		indent = 0
+	}
+
+	rows := []wrapRow{{start: 0}}
+	row := &rows[0]
+	col, lastBreak := 0, -1
+	for i := 0; i < len(line); i++ {
+		w := runeWidthAt(line[i], col, tabsize)
+		if col+w > width && i > row.start {
+			end := i
+			if wordwrap && lastBreak > row.start {
+				end = lastBreak
+			}
+			row.end = end
+			rows = append(rows, wrapRow{start: end, indent: indent})
+			row = &rows[len(rows)-1]
+			lastBreak = -1
+
+			// Measure the runes that moved to the new row again
+			col = indent
+			for j := end; j < i; j++ {
+				col += runeWidthAt(line[j], col, tabsize)
+			}
+			w = runeWidthAt(line[i], col, tabsize)
+		}
+
+		col += w
+		if wordwrap && unicode.IsSpace(line[i]) {
+			lastBreak = i + 1
+		}
+	}
+	row.end = len(line)
+	return rows
+}
+
+// wrapWidth returns the width at which lines are wrapped in a view of the
+// given width
+func (b *Buffer) wrapWidth(width int) int {
+	if cc := b.Settings.Int("colorcolumn"); b.Settings.Bool("wrapatcolorcolumn") && cc > 0 && cc < width {
+		return cc
+	}
+	return width
+}
+
+// wrapLine returns the visual rows of the given line when it is soft wrapped
+// in a view of the given width
+func (b *Buffer) wrapLine(lineN, width int) []wrapRow {
+	line := b.LineRunes(lineN)
+	tabsize := b.Settings.Int("tabsize")
+
+	indent := 0
+	if b.Settings.Bool("wrapindent") {
+		indent = StringWidth(GetLeadingWhitespace(string(line)), tabsize)
+	}
+	indent += runewidth.StringWidth(b.Settings.String("wrapmarker"))
+
+	return wrapLine(line, b.wrapWidth(width), tabsize, b.Settings.Bool("wordwrap"), indent)
+}
+
+// rowColumn returns the visual column of the rune at index x of a line in the
+// given row
+func rowColumn(line []rune, row wrapRow, x, tabsize int) int {
+	col := row.indent
+	for i := row.start; i < x && i < len(line); i++ {
+		col += runeWidthAt(line[i], col, tabsize)
+	}
+	return col
+}
+
+// rowIndex returns the index of the rune of a line that is displayed at the
+// given visual column of the given row
+func rowIndex(line []rune, row wrapRow, col, tabsize int, last bool) int {
+	c := row.indent
+	for i := row.start; i < row.end; i++ {
+		w := runeWidthAt(line[i], c, tabsize)
+		if c+w > col {
+			return i
+		}
+		c += w
+	}
+	if last {
+		return row.end
+	}
+	// The cursor cannot be placed after the last rune of a continued row
+	if row.end > row.start {
+		return row.end - 1
+	}
+	return row.start
+}
+
+// moveVisualRow moves the cursor up (dir < 0) or down (dir > 0) by one visual
+// row of a soft-wrapped buffer, keeping its visual column within the row
+func (v *View) moveVisualRow(dir int) {
+	tabsize := v.Buf.Settings.Int("tabsize")
+	width := v.width - v.lineNumOffset
+
+	rows := v.Buf.wrapLine(v.Cursor.Y, width)
+	line := v.Buf.LineRunes(v.Cursor.Y)
+	r := 0
+	for r < len(rows)-1 && v.Cursor.X >= rows[r].end {
+		r++
+	}
+	col := rowColumn(line, rows[r], v.Cursor.X, tabsize)
+
+	y := v.Cursor.Y
+	r += dir
+	if r < 0 {
+		if y == 0 {
+			v.Cursor.X = 0
+			return
+		}
+		y--
+		rows = v.Buf.wrapLine(y, width)
+		r = len(rows) - 1
+	} else if r >= len(rows) {
+		if y == v.Buf.NumLines-1 {
+			v.Cursor.X = len(line)
+			return
+		}
+		y++
+		rows = v.Buf.wrapLine(y, width)
+		r = 0
+	}
+
+	line = v.Buf.LineRunes(y)
+	v.Cursor.Y = y
+	v.Cursor.X = rowIndex(line, rows[r], col, tabsize, r == len(rows)-1)
+}